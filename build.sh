@@ -2,4 +2,10 @@ set -e
 export GOFLAGS="-mod=vendor"
 
 go build -o bin/ cmd/parser/parser.go
-go build -o bin/ cmd/scrapper/scrapper.go
\ No newline at end of file
+go build -o bin/ cmd/scrapper/scrapper.go
+go build -o bin/ cmd/backfill/backfill.go
+go build -o bin/ cmd/accuracy/accuracy.go
+go build -o bin/ cmd/gaps/gaps.go
+go build -o bin/ cmd/api/api.go
+go build -o bin/ cmd/apikey/apikey.go
+go build -o bin/ cmd/export/export.go
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"temperature/internal/scrapper"
+	"temperature/internal/storage"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	dateLayout  = "02.01.2006"
+	maxLeadDays = 7
+	sheetName   = "Точность прогноза"
+)
+
+const (
+	tableFormat = "table"
+	csvFormat   = "csv"
+	xlsxFormat  = "xlsx"
+)
+
+var header = []string{"Филиал", "Провайдер", "Заблаговременность, сут", "N", "MAE", "Bias", "RMSE"}
+
+type Config struct {
+	Department *string
+	Format     *string
+	Out        *string
+	From       time.Time
+	To         time.Time
+	App        *scrapper.Config
+}
+
+type groupKey struct {
+	department string
+	provider   string
+	lead       int
+}
+
+type Score struct {
+	Department string
+	Provider   string
+	LeadDays   int
+	Count      int
+	MAE        float64
+	Bias       float64
+	RMSE       float64
+}
+
+func main() {
+	config := initApp()
+
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+
+	forecasts, err := db.GetForecasts(*config.Department, &config.From, &config.To)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать прогнозы: %s", err)
+	}
+	query := &storage.TemperatureQuery{From: &config.From, To: &config.To}
+	if *config.Department != "" {
+		query.Departments = []string{*config.Department}
+	}
+	temperatures, err := db.QueryTemperatures(query)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать температуры: %s", err)
+	}
+	scores := evaluate(forecasts, actuals(temperatures))
+	if len(scores) == 0 {
+		log.Warn("Нет пар прогноз - факт за указанный период")
+	}
+
+	if err := write(config, scores); err != nil {
+		log.Fatalf("Не удалось сформировать отчет: %s", err)
+	}
+}
+
+func initApp() *Config {
+	department := flag.String("department", "", "Department, all if empty")
+	format := flag.String("format", tableFormat, "Report format: table, csv, xlsx")
+	out := flag.String("out", "", "Output file, stdout if empty (required for xlsx)")
+	from := flag.String("from", "", "First target day, dd.mm.yyyy")
+	to := flag.String("to", "", "Last target day, dd.mm.yyyy")
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"department": *department,
+		"format":     *format,
+		"out":        *out,
+		"from":       *from,
+		"to":         *to,
+		"config":     *configPath,
+	}
+
+	if *from == "" || *to == "" {
+		log.WithFields(logFields).Fatalf("Указаны не все входные параметры")
+	}
+	if *format != tableFormat && *format != csvFormat && *format != xlsxFormat {
+		log.WithFields(logFields).Fatalf("Неизвестный формат отчета")
+	}
+	if *format == xlsxFormat && *out == "" {
+		log.WithFields(logFields).Fatalf("Для формата xlsx необходимо указать файл")
+	}
+	fromDate, err := time.Parse(dateLayout, *from)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	toDate, err := time.Parse(dateLayout, *to)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+
+	log.WithFields(logFields).Info("Конфигурация")
+
+	return &Config{
+		Department: department,
+		Format:     format,
+		Out:        out,
+		From:       fromDate,
+		To:         toDate,
+		App:        app,
+	}
+}
+
+func actuals(entities []*storage.TemperatureEntity) map[string]map[time.Time]float32 {
+	result := make(map[string]map[time.Time]float32)
+	for _, e := range entities {
+		if e.Estimated {
+			continue
+		}
+		if _, ok := result[e.Department]; !ok {
+			result[e.Department] = make(map[time.Time]float32)
+		}
+		result[e.Department][e.Date.UTC()] = e.Temperature
+	}
+	return result
+}
+
+// evaluate сравнивает каждый снимок прогноза с фактом и считает MAE, смещение и RMSE
+// для филиала, провайдера и заблаговременности 1-7 суток.
+func evaluate(forecasts []*storage.ForecastEntity, actual map[string]map[time.Time]float32) []*Score {
+	errors := make(map[groupKey][]float64)
+	for _, f := range forecasts {
+		if f.LeadDays < 1 || f.LeadDays > maxLeadDays {
+			continue
+		}
+		value, ok := actual[f.Department][f.TargetDate.UTC()]
+		if !ok {
+			continue
+		}
+		key := groupKey{department: f.Department, provider: f.Provider, lead: f.LeadDays}
+		errors[key] = append(errors[key], float64(f.Temperature-value))
+	}
+
+	scores := make([]*Score, 0, len(errors))
+	for key, diffs := range errors {
+		var abs, sum, squares float64
+		for _, d := range diffs {
+			abs += math.Abs(d)
+			sum += d
+			squares += d * d
+		}
+		n := float64(len(diffs))
+		scores = append(scores, &Score{
+			Department: key.department,
+			Provider:   key.provider,
+			LeadDays:   key.lead,
+			Count:      len(diffs),
+			MAE:        abs / n,
+			Bias:       sum / n,
+			RMSE:       math.Sqrt(squares / n),
+		})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.Department != b.Department {
+			return a.Department < b.Department
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.LeadDays < b.LeadDays
+	})
+	return scores
+}
+
+func (s *Score) row() []string {
+	return []string{
+		s.Department,
+		s.Provider,
+		strconv.Itoa(s.LeadDays),
+		strconv.Itoa(s.Count),
+		fmt.Sprintf("%0.2f", s.MAE),
+		fmt.Sprintf("%0.2f", s.Bias),
+		fmt.Sprintf("%0.2f", s.RMSE),
+	}
+}
+
+func write(config *Config, scores []*Score) error {
+	if *config.Format == xlsxFormat {
+		return writeXLSX(*config.Out, scores)
+	}
+
+	var out io.Writer = os.Stdout
+	if *config.Out != "" {
+		file, err := os.Create(*config.Out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if *config.Format == csvFormat {
+		return writeCSV(out, scores)
+	}
+	return writeTable(out, scores)
+}
+
+func writeTable(out io.Writer, scores []*Score) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, s := range scores {
+		fmt.Fprintln(w, strings.Join(s.row(), "\t")+"\t")
+	}
+	return w.Flush()
+}
+
+func writeCSV(out io.Writer, scores []*Score) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, s := range scores {
+		if err := w.Write(s.row()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeXLSX(path string, scores []*Score) error {
+	f := excelize.NewFile()
+	f.SetSheetName(f.GetSheetName(0), sheetName)
+
+	if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+		return err
+	}
+	for i, s := range scores {
+		row := []interface{}{s.Department, s.Provider, s.LeadDays, s.Count,
+			math.Round(s.MAE*100) / 100, math.Round(s.Bias*100) / 100, math.Round(s.RMSE*100) / 100}
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", i+2), &row); err != nil {
+			return err
+		}
+	}
+	return f.SaveAs(path)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"temperature/internal/api"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/scrapper"
+	"time"
+)
+
+type Config struct {
+	Addr string
+	App  *scrapper.Config
+}
+
+func main() {
+	config := initApp()
+
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+	degreeDays := degreedays.New(db, config.App.DegreeDays.BaseTemperature())
+	normals := climate.New(db, config.App.Climate.ExtremeThreshold(), config.App.Climate.MinHistoryYears())
+
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           api.NewServer(db, config.App.Locations, degreeDays, normals).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.WithField("addr", config.Addr).Info("HTTP API запущен")
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("HTTP API остановлен: %s", err)
+	}
+}
+
+func initApp() *Config {
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	addr := flag.String("addr", "", "Listen address, overrides config")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"config": *configPath,
+		"addr":   *addr,
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+	if *addr == "" {
+		*addr = app.API.Address()
+		logFields["addr"] = *addr
+	}
+
+	log.WithFields(logFields).Info("Конфигурация")
+
+	return &Config{
+		Addr: *addr,
+		App:  app,
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"temperature/internal/api"
+	"temperature/internal/scrapper"
+	"temperature/internal/storage"
+	"temperature/internal/weather"
+	"text/tabwriter"
+)
+
+const dateLayout = "02.01.2006 15:04"
+
+type Config struct {
+	Issue       *string
+	Departments []string
+	RateLimit   *int
+	Revoke      *uint
+	App         *scrapper.Config
+}
+
+func main() {
+	config := initApp()
+
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+
+	switch {
+	case *config.Issue != "":
+		key, entity, err := api.IssueKey(db, *config.Issue, config.Departments, *config.RateLimit)
+		if err != nil {
+			log.Fatalf("Не удалось выпустить ключ: %s", err)
+		}
+		log.WithFields(log.Fields{
+			"id":       entity.ID,
+			"название": entity.Name,
+		}).Info("Ключ выпущен, сохраните его: повторно получить ключ нельзя")
+		fmt.Println(key)
+	case *config.Revoke != 0:
+		revoked, err := db.RevokeAPIKey(*config.Revoke)
+		if err != nil {
+			log.Fatalf("Не удалось отозвать ключ: %s", err)
+		}
+		if !revoked {
+			log.Fatalf("Ключ %d не найден или уже отозван", *config.Revoke)
+		}
+		log.WithField("id", *config.Revoke).Info("Ключ отозван")
+	default:
+		if err := list(db); err != nil {
+			log.Fatalf("Не удалось вывести ключи: %s", err)
+		}
+	}
+}
+
+func initApp() *Config {
+	issue := flag.String("issue", "", "Issue a key with the given client name")
+	departments := flag.String("departments", "", "Departments available to the issued key, comma separated, all if empty")
+	rateLimit := flag.Int("rate", 0, "Requests per minute for the issued key, unlimited if 0")
+	revoke := flag.Uint("revoke", 0, "Revoke the key with the given id")
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"issue":       *issue,
+		"departments": *departments,
+		"rate":        *rateLimit,
+		"revoke":      *revoke,
+		"config":      *configPath,
+	}
+
+	if *issue != "" && *revoke != 0 {
+		log.WithFields(logFields).Fatalf("Нельзя одновременно выпустить и отозвать ключ")
+	}
+	if *rateLimit < 0 {
+		log.WithFields(logFields).Fatalf("Ограничение запросов не может быть отрицательным")
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+
+	scope := make([]string, 0)
+	for _, department := range strings.Split(*departments, ",") {
+		if department = strings.TrimSpace(department); department == "" {
+			continue
+		}
+		if !known(app.Locations, department) {
+			log.WithFields(logFields).Fatalf("Филиал не найден в конфигурации: %s", department)
+		}
+		scope = append(scope, department)
+	}
+
+	return &Config{
+		Issue:       issue,
+		Departments: scope,
+		RateLimit:   rateLimit,
+		Revoke:      revoke,
+		App:         app,
+	}
+}
+
+func list(db storage.Storage) error {
+	keys, err := db.GetAPIKeys()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tКлиент\tФилиалы\tЗапросов в минуту\tВыпущен\tОтозван\t")
+	for _, k := range keys {
+		departments := k.Departments
+		if departments == "" {
+			departments = "все"
+		}
+		rateLimit := "без ограничения"
+		if k.RateLimit > 0 {
+			rateLimit = fmt.Sprint(k.RateLimit)
+		}
+		revoked := "-"
+		if k.Revoked() {
+			revoked = k.RevokedAt.Local().Format(dateLayout)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t\n",
+			k.ID, k.Name, departments, rateLimit, k.CreatedAt.Local().Format(dateLayout), revoked)
+	}
+	return w.Flush()
+}
+
+func known(locations []weather.Location, department string) bool {
+	for _, l := range locations {
+		if l.Description == department {
+			return true
+		}
+	}
+	return false
+}
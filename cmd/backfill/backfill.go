@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"temperature/internal/notify"
+	"temperature/internal/scrapper"
+	"temperature/internal/storage"
+	"temperature/internal/validation"
+	"temperature/internal/weather"
+	"time"
+)
+
+const dateLayout = "02.01.2006"
+
+type Config struct {
+	From       time.Time
+	To         time.Time
+	Department *string
+	Delay      *time.Duration
+	App        *scrapper.Config
+}
+
+type task struct {
+	date      time.Time
+	locations []weather.Location
+}
+
+func main() {
+	log.Info("Старт загрузки архива погоды...")
+	config := initApp()
+
+	notifier := notify.NewLogNotifier()
+	api, source, err := initWeatherAPI(config.App, notifier)
+	if err != nil {
+		log.Fatalf("Ошибка инициализации модуля погоды: %s", err)
+	}
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+
+	tasks, err := planTasks(config, db)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать сохраненные даты: %s", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	validator := validation.New(db, config.App.Locations, config.App.Validation)
+	success, errors := run(ctx, api, source, db, validator, notifier, tasks, *config.Delay)
+
+	log.WithFields(log.Fields{
+		"Всего":     len(tasks),
+		"Сохранено": success,
+		"Ошибок":    errors,
+	}).Infof("Загрузка архива завершена")
+}
+
+func initApp() *Config {
+	from := flag.String("from", "", "First day, dd.mm.yyyy")
+	to := flag.String("to", "", "Last day, dd.mm.yyyy")
+	department := flag.String("department", "", "Department, all configured if empty")
+	delay := flag.Duration("delay", time.Second, "Pause between API requests")
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"from":       *from,
+		"to":         *to,
+		"department": *department,
+		"delay":      *delay,
+		"config":     *configPath,
+	}
+
+	if *from == "" || *to == "" || *delay <= 0 {
+		log.WithFields(logFields).Fatalf("Указаны не все входные параметры")
+	}
+	fromDate, err := time.Parse(dateLayout, *from)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	toDate, err := time.Parse(dateLayout, *to)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	if toDate.Before(fromDate) {
+		log.WithFields(logFields).Fatalf("Дата окончания раньше даты начала")
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+
+	log.WithFields(logFields).Info("Конфигурация")
+
+	return &Config{
+		From:       fromDate,
+		To:         toDate,
+		Department: department,
+		Delay:      delay,
+		App:        app,
+	}
+}
+
+func initWeatherAPI(config *scrapper.Config, notifier notify.Notifier) (*weather.API, weather.RecordingSource, error) {
+	source, err := scrapper.NewWeatherSource(&config.Weather, notifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	aggregation, err := weather.ParseAggregation(config.Weather.Aggregation)
+	if err != nil {
+		return nil, nil, err
+	}
+	api, err := weather.New(source, aggregation)
+	return api, source, err
+}
+
+// planTasks пропускает уже сохраненные дни, поэтому прерванную загрузку можно просто запустить повторно.
+func planTasks(config *Config, db storage.Storage) ([]*task, error) {
+	byDate := make(map[time.Time]*task)
+	tasks := make([]*task, 0)
+	for date := config.From; !date.After(config.To); date = date.AddDate(0, 0, 1) {
+		t := task{date: date}
+		byDate[date] = &t
+		tasks = append(tasks, &t)
+	}
+
+	found := false
+	for _, location := range config.App.Locations {
+		if *config.Department != "" && location.Description != *config.Department {
+			continue
+		}
+		found = true
+		stored, err := db.GetStoredDates(location.Description, &config.From, &config.To)
+		if err != nil {
+			return nil, err
+		}
+		for date, t := range byDate {
+			if !stored[date] {
+				t.locations = append(t.locations, location)
+			}
+		}
+	}
+	if !found {
+		log.WithField("department", *config.Department).Fatalf("Филиал не найден в конфигурации")
+	}
+
+	pending := make([]*task, 0, len(tasks))
+	for _, t := range tasks {
+		if len(t.locations) > 0 {
+			pending = append(pending, t)
+		}
+	}
+	log.WithFields(log.Fields{
+		"дней":       len(tasks),
+		"загружено":  len(tasks) - len(pending),
+		"к загрузке": len(pending),
+	}).Info("План загрузки")
+	return pending, nil
+}
+
+func run(ctx context.Context, api *weather.API, source weather.RecordingSource, db storage.Storage, validator *validation.Validator, notifier notify.Notifier, tasks []*task, delay time.Duration) (success int, errors int) {
+	throttle := time.NewTicker(delay)
+	defer throttle.Stop()
+
+	for i, t := range tasks {
+		temps := make([]*weather.Temperature, 0, len(t.locations))
+		for _, location := range t.locations {
+			select {
+			case <-ctx.Done():
+				log.Warn("Загрузка прервана, для продолжения запустите команду повторно")
+				return success, errors
+			case <-throttle.C:
+			}
+
+			location := location
+			temp, err := api.TemperatureOfDateByLocation(ctx, &t.date, &location)
+			if ctx.Err() != nil {
+				log.Warn("Загрузка прервана, для продолжения запустите команду повторно")
+				return success, errors
+			}
+			if err != nil {
+				log.WithFields(log.Fields{
+					"дата":   t.date.Format(dateLayout),
+					"филиал": location.Description,
+				}).Errorf("Не удалось получить значение: %s", err)
+				errors++
+				continue
+			}
+			temperature := weather.NewTemperature(&location, temp)
+			temperature.Source = strings.Join(scrapper.AnsweredBy(source, &location), ",")
+			temps = append(temps, temperature)
+		}
+
+		valid, quarantined, err := scrapper.Validate(validator, notifier, &t.date, temps)
+		if err != nil {
+			log.WithField("дата", t.date.Format(dateLayout)).Errorf("Не удалось проверить значения: %s", err)
+			errors += len(temps)
+		}
+		errors += quarantined
+		temps = valid
+
+		if len(temps) > 0 {
+			if err := db.SaveTemperatureByDate(&t.date, temps); err != nil {
+				log.WithField("дата", t.date.Format(dateLayout)).Errorf("Не удалось сохранить значения: %s", err)
+				errors += len(temps)
+			} else {
+				success += len(temps)
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"дата":     t.date.Format(dateLayout),
+			"прогресс": progress(i+1, len(tasks)),
+		}).Infof("[%d/%d] День обработан", i+1, len(tasks))
+	}
+	return success, errors
+}
+
+func progress(done int, total int) string {
+	return fmt.Sprintf("%d%%", done*100/total)
+}
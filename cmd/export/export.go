@@ -0,0 +1,380 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/xuri/excelize/v2"
+	"math"
+	"strings"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/scrapper"
+	"temperature/internal/storage"
+	"time"
+)
+
+const (
+	dateLayout  = "02.01.2006"
+	monthLayout = "01.2006"
+	// maxSheetName - ограничение Excel на длину имени листа.
+	maxSheetName = 31
+	// invalidSheetChars - символы, недопустимые в имени листа.
+	invalidSheetChars = `:\/?*[]`
+	// defaultSheetName - имя листа филиала, в названии которого нет допустимых символов.
+	defaultSheetName = "Филиал"
+)
+
+// sheetRef экранирует имя листа для ссылки в JSON-описании графика.
+var sheetRef = strings.NewReplacer("'", "''", `"`, `\"`)
+
+var (
+	dailyHeader   = []interface{}{"Дата", "Температура, °C", "Мин, °C", "Макс, °C", "ГСОП", "ГСОП с начала периода", "Восстановлено"}
+	monthlyHeader = []interface{}{"Месяц", "Средняя, °C", "ГСОП за месяц", "Дней с данными", "Норма, °C", "Отклонение от нормы, °C"}
+)
+
+// Столбцы таблиц на листе филиала.
+const (
+	dailyCol       = "A"
+	temperatureCol = "B"
+	monthlyCol     = "I"
+	lastCol        = "N"
+	chartCol       = "P"
+)
+
+type Config struct {
+	From       time.Time
+	To         time.Time
+	Department *string
+	Template   *string
+	Out        *string
+	App        *scrapper.Config
+}
+
+// report - данные листа одного филиала.
+type report struct {
+	department   string
+	temperatures []*storage.TemperatureEntity
+	degreeDays   map[time.Time]*degreedays.Day
+	months       []*storage.Aggregate
+	monthly      map[time.Time]float32
+	normals      map[*storage.Aggregate]*climate.PeriodNormal
+}
+
+func main() {
+	config := initApp()
+
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+	calculator := degreedays.New(db, config.App.DegreeDays.BaseTemperature())
+	normals := climate.New(db, config.App.Climate.ExtremeThreshold(), config.App.Climate.MinHistoryYears())
+
+	f, base, err := openWorkbook(config)
+	if err != nil {
+		log.Fatalf("Не удалось открыть шаблон: %s", err)
+	}
+	styles, err := newStyles(f)
+	if err != nil {
+		log.Fatalf("Не удалось создать стили: %s", err)
+	}
+
+	sheets := 0
+	used := map[string]bool{strings.ToLower(base): true}
+	for _, location := range config.App.Locations {
+		if *config.Department != "" && location.Description != *config.Department {
+			continue
+		}
+		logFields := log.Fields{"филиал": location.Description}
+		r, err := load(db, calculator, normals, location.Description, &config.From, &config.To)
+		if err != nil {
+			log.WithFields(logFields).Fatalf("Не удалось прочитать данные: %s", err)
+		}
+		if len(r.temperatures) == 0 {
+			log.WithFields(logFields).Warn("Нет данных за период, лист не создан")
+			continue
+		}
+		if err := writeSheet(f, base, sheetName(r.department, used), styles, config, r); err != nil {
+			log.WithFields(logFields).Fatalf("Не удалось заполнить лист: %s", err)
+		}
+		sheets++
+	}
+	if sheets == 0 {
+		log.Fatal("Нет данных за указанный период")
+	}
+
+	f.DeleteSheet(base)
+	f.SetActiveSheet(0)
+	if err := f.SaveAs(*config.Out); err != nil {
+		log.Fatalf("Не удалось сохранить отчет: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"файл":   *config.Out,
+		"листов": sheets,
+	}).Info("Отчет сформирован")
+}
+
+func initApp() *Config {
+	from := flag.String("from", "", "First day, dd.mm.yyyy")
+	to := flag.String("to", "", "Last day, dd.mm.yyyy")
+	department := flag.String("department", "", "Department, all configured if empty")
+	template := flag.String("template", "", "Workbook whose first sheet is copied for every department, data is placed below its content")
+	out := flag.String("out", "", "Output xlsx file")
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"from":       *from,
+		"to":         *to,
+		"department": *department,
+		"template":   *template,
+		"out":        *out,
+		"config":     *configPath,
+	}
+
+	if *from == "" || *to == "" || *out == "" {
+		log.WithFields(logFields).Fatalf("Указаны не все входные параметры")
+	}
+	fromDate, err := time.Parse(dateLayout, *from)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	toDate, err := time.Parse(dateLayout, *to)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	if toDate.Before(fromDate) {
+		log.WithFields(logFields).Fatalf("Дата окончания раньше даты начала")
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+
+	log.WithFields(logFields).Info("Конфигурация")
+
+	return &Config{
+		From:       fromDate,
+		To:         toDate,
+		Department: department,
+		Template:   template,
+		Out:        out,
+		App:        app,
+	}
+}
+
+// openWorkbook открывает шаблон или создает пустую книгу. Возвращает имя листа,
+// который копируется для каждого филиала и удаляется перед сохранением.
+func openWorkbook(config *Config) (*excelize.File, string, error) {
+	if *config.Template == "" {
+		f := excelize.NewFile()
+		return f, f.GetSheetName(0), nil
+	}
+	f, err := excelize.OpenFile(*config.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, f.GetSheetName(0), nil
+}
+
+func load(db storage.Storage, calculator *degreedays.Calculator, normals *climate.Normals, department string, from *time.Time, to *time.Time) (*report, error) {
+	query := &storage.TemperatureQuery{Departments: []string{department}, From: from, To: to}
+	temperatures, err := db.QueryTemperatures(query)
+	if err != nil {
+		return nil, err
+	}
+	months, err := db.AggregateTemperatures(query, storage.MonthPeriod)
+	if err != nil {
+		return nil, err
+	}
+	days, err := calculator.Period(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	monthNormals, err := normals.Periods(months, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &report{
+		department:   department,
+		temperatures: temperatures,
+		degreeDays:   make(map[time.Time]*degreedays.Day, len(days)),
+		months:       months,
+		monthly:      make(map[time.Time]float32),
+		normals:      monthNormals,
+	}
+	for _, d := range days {
+		r.degreeDays[d.Date] = d
+		r.monthly[time.Date(d.Date.Year(), d.Date.Month(), 1, 0, 0, 0, 0, time.UTC)] += d.Value
+	}
+	return r, nil
+}
+
+type styles struct {
+	title  int
+	header int
+	date   int
+	number int
+}
+
+func newStyles(f *excelize.File) (*styles, error) {
+	var s styles
+	var err error
+	if s.title, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}); err != nil {
+		return nil, err
+	}
+	if s.header, err = f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Alignment: &excelize.Alignment{WrapText: true, Vertical: "center"},
+	}); err != nil {
+		return nil, err
+	}
+	dateFormat := "dd.mm.yyyy"
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return nil, err
+	}
+	numberFormat := "0.0"
+	if s.number, err = f.NewStyle(&excelize.Style{CustomNumFmt: &numberFormat}); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// writeSheet копирует лист шаблона для филиала и заполняет его под содержимым шаблона:
+// суточные значения с ГСОП, средние за месяц с отклонением от нормы и график температуры.
+func writeSheet(f *excelize.File, base string, sheet string, s *styles, config *Config, r *report) error {
+	if err := f.CopySheet(f.GetSheetIndex(base), f.NewSheet(sheet)); err != nil {
+		return err
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return err
+	}
+	start := 1
+	if len(rows) > 0 {
+		start = len(rows) + 2
+	}
+
+	title := fmt.Sprintf("%s: температура и ГСОП (база %0.0f°C) с %s по %s", r.department,
+		config.App.DegreeDays.BaseTemperature(), config.From.Format(dateLayout), config.To.Format(dateLayout))
+	if err := f.SetCellValue(sheet, cell(dailyCol, start), title); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, cell(dailyCol, start), cell(dailyCol, start), s.title); err != nil {
+		return err
+	}
+
+	header := start + 2
+	if err := f.SetSheetRow(sheet, cell(dailyCol, header), &dailyHeader); err != nil {
+		return err
+	}
+	if err := f.SetSheetRow(sheet, cell(monthlyCol, header), &monthlyHeader); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, cell(dailyCol, header), cell(lastCol, header), s.header); err != nil {
+		return err
+	}
+
+	first := header + 1
+	for i, t := range r.temperatures {
+		date := t.Date.UTC()
+		row := []interface{}{date, round(t.Temperature), round(t.Min), round(t.Max), nil, nil, nil}
+		if d, ok := r.degreeDays[date]; ok {
+			row[4], row[5] = round(d.Value), round(d.Cumulative)
+		}
+		if t.Estimated {
+			row[6] = "да"
+		}
+		if err := f.SetSheetRow(sheet, cell(dailyCol, first+i), &row); err != nil {
+			return err
+		}
+	}
+	last := first + len(r.temperatures) - 1
+	if err := f.SetCellStyle(sheet, cell(dailyCol, first), cell(dailyCol, last), s.date); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheet, cell(temperatureCol, first), cell("F", last), s.number); err != nil {
+		return err
+	}
+
+	for i, m := range r.months {
+		row := []interface{}{m.From.Format(monthLayout), round(m.Mean), round(r.monthly[m.From]), m.Days, nil, nil}
+		if normal, ok := r.normals[m]; ok {
+			row[4], row[5] = round(normal.Normal), round(normal.Deviation)
+		}
+		if err := f.SetSheetRow(sheet, cell(monthlyCol, first+i), &row); err != nil {
+			return err
+		}
+	}
+	if len(r.months) > 0 {
+		if err := f.SetCellStyle(sheet, cell("J", first), cell("K", first+len(r.months)-1), s.number); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheet, cell("M", first), cell(lastCol, first+len(r.months)-1), s.number); err != nil {
+			return err
+		}
+	}
+
+	if err := f.SetColWidth(sheet, "A", "G", 14); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sheet, monthlyCol, lastCol, 14); err != nil {
+		return err
+	}
+	return f.AddChart(sheet, cell(chartCol, header), fmt.Sprintf(`{
+		"type": "line",
+		"series": [{
+			"name": "'%[1]s'!$%[2]s$%[3]d",
+			"categories": "'%[1]s'!$%[4]s$%[5]d:$%[4]s$%[6]d",
+			"values": "'%[1]s'!$%[2]s$%[5]d:$%[2]s$%[6]d"
+		}],
+		"format": {"x_scale": 2.0, "y_scale": 1.5},
+		"title": {"name": "Среднесуточная температура, °C"},
+		"legend": {"none": true},
+		"show_blanks_as": "gap"
+	}`, sheetRef.Replace(sheet), temperatureCol, header, dailyCol, first, last))
+}
+
+// sheetName приводит название филиала к допустимому имени листа, которого еще нет в used.
+// Excel сравнивает имена листов без учета регистра, поэтому совпавшие после обрезки имена нумеруются.
+func sheetName(department string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(invalidSheetChars, r) {
+			return -1
+		}
+		return r
+	}, department)
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if name == "" {
+		name = defaultSheetName
+	}
+
+	candidate := truncate(name, maxSheetName)
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncate(name, maxSheetName-len([]rune(suffix))) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// truncate обрезает имя до n символов. Имя листа не может заканчиваться апострофом.
+func truncate(name string, n int) string {
+	runes := []rune(name)
+	if len(runes) > n {
+		runes = runes[:n]
+	}
+	return strings.TrimRight(string(runes), "'")
+}
+
+func round(v float32) float64 {
+	return math.Round(float64(v)*10) / 10
+}
+
+func cell(col string, row int) string {
+	return fmt.Sprintf("%s%d", col, row)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"temperature/internal/gaps"
+	"temperature/internal/scrapper"
+	"temperature/internal/storage"
+	"text/tabwriter"
+	"time"
+)
+
+const dateLayout = "02.01.2006"
+
+type Config struct {
+	From       time.Time
+	To         time.Time
+	Department *string
+	Fill       *string
+	MaxDays    *int
+	App        *scrapper.Config
+}
+
+func main() {
+	config := initApp()
+
+	db, err := config.App.OpenStorage()
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+
+	found := make([]*storage.Gap, 0)
+	for _, location := range config.App.Locations {
+		if *config.Department != "" && location.Description != *config.Department {
+			continue
+		}
+		departmentGaps, err := db.GetGaps(location.Description, &config.From, &config.To)
+		if err != nil {
+			log.Fatalf("Не удалось найти пропуски: %s", err)
+		}
+		found = append(found, departmentGaps...)
+	}
+
+	filled := make(map[*storage.Gap]bool)
+	if *config.Fill != "" {
+		filled = fill(config, db, found)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Филиал\tС\tПо\tДней\tВосстановлено\t")
+	for _, gap := range found {
+		status := "нет"
+		if filled[gap] {
+			status = "да"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t\n",
+			gap.Department, gap.From.Format(dateLayout), gap.To.Format(dateLayout), gap.Days(), status)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Не удалось вывести отчет: %s", err)
+	}
+	log.WithFields(log.Fields{
+		"Пропусков":     len(found),
+		"Восстановлено": len(filled),
+	}).Info("Поиск пропусков завершен")
+}
+
+func initApp() *Config {
+	from := flag.String("from", "", "First day, dd.mm.yyyy")
+	to := flag.String("to", "", "Last day, dd.mm.yyyy")
+	department := flag.String("department", "", "Department, all configured if empty")
+	fillMethod := flag.String("fill", "", "Fill gaps: linear, neighbour; report only if empty")
+	maxDays := flag.Int("max", gaps.DefaultMaxDays, "Longest gap to fill, days")
+	configPath := flag.String("config", scrapper.ConfigPath, "Config directory")
+	dbPath := flag.String("db", "", "Database path or DSN, overrides config")
+	flag.Parse()
+
+	logFields := log.Fields{
+		"from":       *from,
+		"to":         *to,
+		"department": *department,
+		"fill":       *fillMethod,
+		"max":        *maxDays,
+		"config":     *configPath,
+	}
+
+	if *from == "" || *to == "" {
+		log.WithFields(logFields).Fatalf("Указаны не все входные параметры")
+	}
+	if *fillMethod != "" {
+		if _, err := gaps.ParseMethod(*fillMethod); err != nil {
+			log.WithFields(logFields).Fatal(err)
+		}
+	}
+	fromDate, err := time.Parse(dateLayout, *from)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	toDate, err := time.Parse(dateLayout, *to)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Неверный формат даты: %s", err)
+	}
+	if toDate.Before(fromDate) {
+		log.WithFields(logFields).Fatalf("Дата окончания раньше даты начала")
+	}
+
+	app, err := scrapper.LoadConfig(*configPath, *dbPath)
+	if err != nil {
+		log.WithFields(logFields).Fatalf("Не удалось прочитать конфигурацию: %s", err)
+	}
+
+	log.WithFields(logFields).Info("Конфигурация")
+
+	return &Config{
+		From:       fromDate,
+		To:         toDate,
+		Department: department,
+		Fill:       fillMethod,
+		MaxDays:    maxDays,
+		App:        app,
+	}
+}
+
+// fill восстанавливает пропуски не длиннее MaxDays.
+func fill(config *Config, db storage.Storage, found []*storage.Gap) map[*storage.Gap]bool {
+	method, _ := gaps.ParseMethod(*config.Fill)
+	filler := gaps.NewFiller(db, config.App.Locations)
+
+	filled := make(map[*storage.Gap]bool)
+	for _, gap := range found {
+		logFields := log.Fields{
+			"филиал": gap.Department,
+			"с":      gap.From.Format(dateLayout),
+			"по":     gap.To.Format(dateLayout),
+		}
+		if gap.Days() > *config.MaxDays {
+			log.WithFields(logFields).Warn("Пропуск слишком длинный для восстановления")
+			continue
+		}
+		if _, err := filler.Fill(gap, method); err != nil {
+			log.WithFields(logFields).Errorf("Не удалось восстановить пропуск: %s", err)
+			continue
+		}
+		filled[gap] = true
+	}
+	return filled
+}
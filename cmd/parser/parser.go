@@ -5,30 +5,58 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/xuri/excelize/v2"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"temperature/internal/storage"
+	"temperature/internal/weather"
 	"time"
 )
 
 const (
-	startRow       = 8
-	dateCol        = "A"
-	temperatureCol = "B"
+	sheetName        = "Архив Погоды rp5"
+	startRow         = 8
+	dateCol          = "A"
+	temperatureCol   = "B"
+	humidityCol      = "F"
+	windSpeedCol     = "H"
+	windGustCol      = "I"
+	precipitationCol = "X"
+	snowDepthCol     = "AC"
+	dateLayout       = "02.01.2006 15:04"
 )
 
+// Текстовые значения rp5, означающие отсутствие осадков или снежного покрова.
+var zeroValues = map[string]bool{
+	"Осадков нет":                   true,
+	"Следы осадков":                 true,
+	"Снежный покров не постоянный.": true,
+	"Менее 0.5":                     true,
+}
+
 type Config struct {
-	Filepath   *string
-	Department *string
-	DBPath     *string
+	Filepath    *string
+	Department  *string
+	DBPath      *string
+	Driver      *string
+	Aggregation weather.Aggregation
+	Location    *time.Location
+	Coordinates weather.Coordinates
+	Upsert      storage.UpsertPolicy
+	Priorities  []string
+}
+
+type parsedDay struct {
+	entity       *storage.TemperatureEntity
+	observations []*storage.ObservationEntity
 }
 
 func main() {
 	log.Info("Старт парсинга архивов rp5.ru...")
 	config := initApp()
 
-	resultChan := parseFile(config.Filepath)
-	success, errors := save(config.DBPath, addDepartmentField(config.Department, resultChan))
+	resultChan := parseFile(config)
+	success, errors := save(config, addDepartmentField(config.Department, resultChan))
 
 	log.WithFields(log.Fields{
 		"Всего":     success + errors,
@@ -40,42 +68,81 @@ func main() {
 func initApp() *Config {
 	parsePath := flag.String("file", "", "Parse file path")
 	department := flag.String("department", "", "Department")
-	dbPath := flag.String("db", "", "Database path")
+	dbPath := flag.String("db", "", "Database path or DSN")
+	driver := flag.String("driver", storage.SQLiteDriver, "Database driver: sqlite, postgres")
+	aggregation := flag.String("aggregation", string(weather.MeanAggregation), "Daily aggregation: mean, min, max, synoptic8")
+	timezone := flag.String("timezone", "", "Timezone of the archive local time, e.g. Asia/Chita")
+	lat := flag.Float64("lat", 0, "Weather station latitude")
+	lon := flag.Float64("lon", 0, "Weather station longitude")
+	upsert := flag.String("upsert", string(storage.OverwritePolicy), "Policy for stored days: keep-first, overwrite, priority")
+	priorities := flag.String("priorities", "", "Comma separated sources from most to least trusted, for priority policy")
 	flag.Parse()
 
 	logFields := log.Fields{
-		"path":       *parsePath,
-		"department": *department,
-		"dbPath":     *dbPath,
+		"path":        *parsePath,
+		"department":  *department,
+		"dbPath":      *dbPath,
+		"driver":      *driver,
+		"aggregation": *aggregation,
+		"timezone":    *timezone,
+		"lat":         *lat,
+		"lon":         *lon,
+		"upsert":      *upsert,
+		"priorities":  *priorities,
 	}
 
-	if *parsePath == "" || *department == "" || *dbPath == "" {
+	if *parsePath == "" || *department == "" || *dbPath == "" || *timezone == "" {
 		log.WithFields(logFields).Fatalf("Указаны не все входные параметры")
 	}
+	method, err := weather.ParseAggregation(*aggregation)
+	if err != nil {
+		log.WithFields(logFields).Fatalln(err)
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.WithFields(logFields).Fatalln(err)
+	}
+	policy, err := storage.ParseUpsertPolicy(*upsert)
+	if err != nil {
+		log.WithFields(logFields).Fatalln(err)
+	}
+	var sources []string
+	if *priorities != "" {
+		sources = strings.Split(*priorities, ",")
+	}
 
-	log.WithFields(logFields).Fatalf("Конфигурация")
+	log.WithFields(logFields).Info("Конфигурация")
 
 	return &Config{
-		Filepath:   parsePath,
-		Department: department,
-		DBPath:     dbPath,
+		Filepath:    parsePath,
+		Department:  department,
+		DBPath:      dbPath,
+		Driver:      driver,
+		Aggregation: method,
+		Location:    location,
+		Coordinates: weather.Coordinates{
+			Lat: float32(*lat),
+			Lon: float32(*lon),
+		},
+		Upsert:     policy,
+		Priorities: sources,
 	}
 }
 
-func parseFile(path *string) <-chan *storage.TemperatureEntity {
-	f, err := excelize.OpenFile(*path)
+func parseFile(config *Config) <-chan *parsedDay {
+	f, err := excelize.OpenFile(*config.Filepath)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	results := make(chan *storage.TemperatureEntity, 40)
+	results := make(chan *parsedDay, 40)
 
 	go func() {
-		acc := make([]float32, 0, 8)
+		acc := make([]weather.Observation, 0, 8)
 		var prevDate *time.Time
 		row := startRow
 		for {
-			date, err := getDate(f, row)
+			date, err := getDate(f, row, config.Location)
 			if err != nil {
 				break
 			}
@@ -86,28 +153,58 @@ func parseFile(path *string) <-chan *storage.TemperatureEntity {
 			}
 
 			if prevDate.Day() != date.Day() {
-				entity := storage.TemperatureEntity{
-					Temperature: average(acc),
-					Day:         prevDate.Day(),
-					Month:       int(prevDate.Month()),
-					Year:        prevDate.Year(),
+				if day := newDay(prevDate, acc, config.Aggregation, filepath.Base(*config.Filepath)); day != nil {
+					results <- day
 				}
-				results <- &entity
 				acc = clearAcc(acc)
 			}
 
-			acc = append(acc, temp)
+			acc = append(acc, weather.Observation{
+				Coordinates: config.Coordinates,
+				Time:        *date,
+				Temperature: temp,
+				Variables:   getVariables(f, row),
+			})
 			prevDate = date
 			row++
 		}
+		if len(acc) > 0 {
+			if day := newDay(prevDate, acc, config.Aggregation, filepath.Base(*config.Filepath)); day != nil {
+				results <- day
+			}
+		}
 		close(results)
 	}()
 
 	return results
 }
 
-func getDate(f *excelize.File, row int) (*time.Time, error) {
-	dateCell, err := f.GetCellValue("Архив Погоды rp5", fmt.Sprintf("%s%d", dateCol, row))
+// newDay рассчитывает суточные значения по тем же наблюдениям, которые сохраняются почасово.
+func newDay(date *time.Time, acc []weather.Observation, aggregation weather.Aggregation, source string) *parsedDay {
+	daily, err := aggregation.Summarize(acc)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"date": date.Format("02.01.2006"),
+		}).Errorf("Не удалось рассчитать значение за день: %s", err)
+		return nil
+	}
+	return &parsedDay{
+		entity: &storage.TemperatureEntity{
+			Temperature: daily.Mean,
+			Min:         daily.Min,
+			Max:         daily.Max,
+			Date:        time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+			Source:      source,
+			IngestedAt:  time.Now().UTC(),
+			Aggregation: string(aggregation),
+			Samples:     len(acc),
+		},
+		observations: storage.NewObservationEntities("", acc),
+	}
+}
+
+func getDate(f *excelize.File, row int, location *time.Location) (*time.Time, error) {
+	dateCell, err := f.GetCellValue(sheetName, fmt.Sprintf("%s%d", dateCol, row))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"row": row,
@@ -116,11 +213,11 @@ func getDate(f *excelize.File, row int) (*time.Time, error) {
 	if dateCell == "" {
 		return nil, fmt.Errorf("EOF")
 	}
-	return extractDate(dateCell), nil
+	return extractDate(dateCell, location), nil
 }
 
 func getTemp(f *excelize.File, row int) float32 {
-	tempCell, err := f.GetCellValue("Архив Погоды rp5", fmt.Sprintf("%s%d", temperatureCol, row))
+	tempCell, err := f.GetCellValue(sheetName, fmt.Sprintf("%s%d", temperatureCol, row))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"row": row,
@@ -130,55 +227,89 @@ func getTemp(f *excelize.File, row int) float32 {
 	return float32(temp)
 }
 
-func extractDate(s string) *time.Time {
+func getVariables(f *excelize.File, row int) weather.Variables {
+	return weather.Variables{
+		WindSpeed:     getOptional(f, windSpeedCol, row),
+		WindGust:      getOptional(f, windGustCol, row),
+		Precipitation: getOptional(f, precipitationCol, row),
+		Humidity:      getOptional(f, humidityCol, row),
+		SnowDepth:     getOptional(f, snowDepthCol, row),
+	}
+}
+
+func getOptional(f *excelize.File, col string, row int) *float32 {
+	cell, err := f.GetCellValue(sheetName, fmt.Sprintf("%s%d", col, row))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"row": row,
+		}).Fatalln("Ошибка чтения строки")
+	}
+	cell = strings.TrimSpace(cell)
+	if zeroValues[cell] {
+		var value float32 = 0
+		return &value
+	}
+	parsed, err := strconv.ParseFloat(cell, 32)
+	if err != nil {
+		return nil
+	}
+	value := float32(parsed)
+	return &value
+}
+
+func extractDate(s string, location *time.Location) *time.Time {
+	date, err := time.ParseInLocation(dateLayout, strings.TrimSpace(s), location)
+	if err == nil {
+		return &date
+	}
 	s = strings.Fields(s)[0]
 	arr := strings.Split(s, ".")
 	day, _ := strconv.Atoi(arr[0])
 	month, _ := strconv.Atoi(arr[1])
 	year, _ := strconv.Atoi(arr[2])
-	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
 	return &date
 }
 
-func average(items []float32) float32 {
-	var sum float32 = 0.0
-	for _, i := range items {
-		sum += i
-	}
-	return sum / float32(len(items))
-}
-
-func clearAcc(slice []float32) []float32 {
+func clearAcc(slice []weather.Observation) []weather.Observation {
 	return slice[:0]
 }
 
-func addDepartmentField(department *string, result <-chan *storage.TemperatureEntity) <-chan *storage.TemperatureEntity {
-	transformChan := make(chan *storage.TemperatureEntity, cap(result))
+func addDepartmentField(department *string, result <-chan *parsedDay) <-chan *parsedDay {
+	transformChan := make(chan *parsedDay, cap(result))
 	go func() {
-		for entity := range result {
-			entity.Department = *department
-			transformChan <- entity
+		for day := range result {
+			day.entity.Department = *department
+			for _, observation := range day.observations {
+				observation.Department = *department
+			}
+			transformChan <- day
 		}
 		close(transformChan)
 	}()
 	return transformChan
 }
 
-func save(path *string, results <-chan *storage.TemperatureEntity) (success int, errors int) {
-	repo := storage.New(path)
-	err := repo.Init()
+func save(config *Config, results <-chan *parsedDay) (success int, errors int) {
+	repo, err := storage.Open(*config.Driver, config.DBPath)
+	if err != nil {
+		log.Fatalf("Cannot open DB: %s", err)
+	}
+	err = repo.Init()
 	if err != nil {
 		log.Fatalf("Cannot open DB: %s", err)
 	}
+	repo.SetUpsertPolicy(config.Upsert, config.Priorities)
 
 	success = 0
 	errors = 0
 
-	for entity := range results {
-		err := repo.Save(entity)
-		if err != nil {
+	for day := range results {
+		entity := day.entity
+		entity.Observations = day.observations
+		if err := repo.Save(entity); err != nil {
 			log.WithFields(log.Fields{
-				"date": fmt.Sprintf("%s.%s.%s", entity.Day, entity.Month, entity.Year),
+				"date": entity.Date.Format("02.01.2006"),
 			}).Errorln("Не удалось сохранить значение")
 			errors++
 		} else {
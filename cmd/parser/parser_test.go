@@ -0,0 +1,58 @@
+package main
+
+import (
+	"temperature/internal/weather"
+	"testing"
+	"time"
+)
+
+func TestParseFileEmitsEveryDay(t *testing.T) {
+	path := "testdata/rp5_archive.xlsx"
+	chita, err := time.LoadLocation("Asia/Chita")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Filepath:    &path,
+		Aggregation: weather.MeanAggregation,
+		Location:    chita,
+		Coordinates: weather.Coordinates{Lat: 52, Lon: 113.5},
+	}
+
+	days := make([]*parsedDay, 0, 2)
+	for day := range parseFile(config) {
+		days = append(days, day)
+	}
+
+	// Архив rp5 идет от новых строк к старым, последний день файла - самый ранний.
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2", len(days))
+	}
+	tests := []struct {
+		date     time.Time
+		mean     float32
+		min      float32
+		max      float32
+		samples  int
+		humidity bool
+	}{
+		{time.Date(2022, time.January, 5, 0, 0, 0, 0, time.UTC), -26, -30, -20, 3, true},
+		{time.Date(2022, time.January, 4, 0, 0, 0, 0, time.UTC), -22, -26, -18, 2, false},
+	}
+	for i, tt := range tests {
+		e := days[i].entity
+		if !e.Date.Equal(tt.date) || e.Temperature != tt.mean || e.Min != tt.min || e.Max != tt.max || e.Samples != tt.samples {
+			t.Errorf("day %d: got %s %v [%v, %v] of %d samples, want %s %v [%v, %v] of %d",
+				i, e.Date, e.Temperature, e.Min, e.Max, e.Samples, tt.date, tt.mean, tt.min, tt.max, tt.samples)
+		}
+		if e.Source != "rp5_archive.xlsx" {
+			t.Errorf("day %d: got source %q", i, e.Source)
+		}
+		if len(days[i].observations) != tt.samples {
+			t.Errorf("day %d: got %d observations, want %d", i, len(days[i].observations), tt.samples)
+		}
+		if got := days[i].observations[0].Humidity != nil; got != tt.humidity {
+			t.Errorf("day %d: humidity present %v, want %v", i, got, tt.humidity)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"temperature/internal/storage"
+	"time"
+)
+
+// keyPrefix отличает ключи API от других секретов в конфигурациях клиентов.
+const keyPrefix = "tk_"
+
+type contextKey struct{}
+
+// IssueKey создает ключ доступа к API. Ключ возвращается только здесь, в БД сохраняется его хеш.
+// Пустой departments означает доступ ко всем филиалам, нулевой rateLimit - без ограничения числа запросов.
+func IssueKey(s storage.Storage, name string, departments []string, rateLimit int) (string, *storage.APIKeyEntity, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := keyPrefix + hex.EncodeToString(secret)
+	entity := &storage.APIKeyEntity{
+		Name:        name,
+		Hash:        HashKey(key),
+		Departments: strings.Join(departments, ","),
+		RateLimit:   rateLimit,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.SaveAPIKey(entity); err != nil {
+		return "", nil, err
+	}
+	return key, entity, nil
+}
+
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authorized пропускает запрос с действующим ключом в заголовке Authorization: Bearer или X-API-Key
+// и ограничивает число запросов ключа в минуту. Ключ передается обработчику через контекст запроса.
+func (s *Server) authorized(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		token := r.Header.Get("X-API-Key")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return &httpError{status: http.StatusUnauthorized, message: "Не указан ключ доступа"}
+		}
+		key, err := s.storage.GetAPIKey(HashKey(token))
+		if err != nil {
+			return err
+		}
+		if key == nil || key.Revoked() {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return &httpError{status: http.StatusUnauthorized, message: "Недействительный ключ доступа"}
+		}
+		if ok, retry := s.limiter.allow(key); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			return &httpError{status: http.StatusTooManyRequests, message: "Превышено допустимое число запросов"}
+		}
+		return handler(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, key)))
+	}
+}
+
+// scope возвращает филиалы, доступные ключу запроса, nil означает все филиалы.
+func scope(r *http.Request) []string {
+	key, ok := r.Context().Value(contextKey{}).(*storage.APIKeyEntity)
+	if !ok {
+		return nil
+	}
+	return key.Scope()
+}
+
+func allowed(scope []string, department string) bool {
+	if scope == nil {
+		return true
+	}
+	for _, d := range scope {
+		if d == department {
+			return true
+		}
+	}
+	return false
+}
+
+func forbidden(department string) error {
+	return &httpError{status: http.StatusForbidden, message: fmt.Sprintf("Нет доступа к филиалу: %s", department)}
+}
+
+// limiter считает запросы каждого ключа за минуту, отсчитываемую от первого запроса.
+type limiter struct {
+	mu      sync.Mutex
+	windows map[uint]*window
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func newLimiter() *limiter {
+	return &limiter{windows: make(map[uint]*window)}
+}
+
+// allow учитывает запрос и возвращает false и время до начала следующей минуты, если лимит ключа исчерпан.
+func (l *limiter) allow(key *storage.APIKeyEntity) (bool, time.Duration) {
+	if key.RateLimit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.windows[key.ID]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		w = &window{start: now}
+		l.windows[key.ID] = w
+	}
+	if w.count >= key.RateLimit {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
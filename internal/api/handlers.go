@@ -0,0 +1,513 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/storage"
+	"temperature/internal/weather"
+	"time"
+)
+
+type Department struct {
+	Name        string       `json:"name"`
+	Timezone    string       `json:"timezone"`
+	Coordinates []Coordinate `json:"coordinates"`
+}
+
+type Coordinate struct {
+	Lat float32 `json:"lat"`
+	Lon float32 `json:"lon"`
+}
+
+type Temperature struct {
+	Department  string  `json:"department"`
+	Date        string  `json:"date"`
+	Temperature float32 `json:"temperature"`
+	Min         float32 `json:"min"`
+	Max         float32 `json:"max"`
+	Estimated   bool    `json:"estimated"`
+	Source      string  `json:"source"`
+	Aggregation string  `json:"aggregation"`
+}
+
+type Aggregate struct {
+	Department string   `json:"department"`
+	Period     string   `json:"period"`
+	Year       int      `json:"year"`
+	Number     int      `json:"number"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Mean       float32  `json:"mean"`
+	Min        float32  `json:"min"`
+	Max        float32  `json:"max"`
+	Days       int      `json:"days"`
+	Estimated  int      `json:"estimated"`
+	Normal     *float32 `json:"normal"`
+	Anomaly    *float32 `json:"anomaly"`
+}
+
+type Observation struct {
+	Department    string   `json:"department"`
+	Time          string   `json:"time"`
+	Lat           float32  `json:"lat"`
+	Lon           float32  `json:"lon"`
+	Temperature   float32  `json:"temperature"`
+	WindSpeed     *float32 `json:"windSpeed"`
+	WindGust      *float32 `json:"windGust"`
+	Precipitation *float32 `json:"precipitation"`
+	Humidity      *float32 `json:"humidity"`
+	SnowDepth     *float32 `json:"snowDepth"`
+}
+
+type Sources struct {
+	Department string   `json:"department"`
+	Sources    []string `json:"sources"`
+}
+
+type Revision struct {
+	Department  string  `json:"department"`
+	Date        string  `json:"date"`
+	Temperature float32 `json:"temperature"`
+	Min         float32 `json:"min"`
+	Max         float32 `json:"max"`
+	Estimated   bool    `json:"estimated"`
+	Source      string  `json:"source"`
+	Aggregation string  `json:"aggregation"`
+	IngestedAt  string  `json:"ingestedAt"`
+	RevisedAt   string  `json:"revisedAt"`
+	ReplacedBy  string  `json:"replacedBy"`
+}
+
+type DegreeDay struct {
+	Department  string  `json:"department"`
+	Date        string  `json:"date"`
+	Base        float32 `json:"base"`
+	Temperature float32 `json:"temperature"`
+	Value       float32 `json:"value"`
+	Cumulative  float32 `json:"cumulative"`
+}
+
+var (
+	temperatureHeader = []string{"department", "date", "temperature", "min", "max", "estimated", "source", "aggregation"}
+	aggregateHeader   = []string{"department", "period", "year", "number", "from", "to", "mean", "min", "max", "days", "estimated", "normal", "anomaly"}
+	observationHeader = []string{"department", "time", "lat", "lon", "temperature", "windSpeed", "windGust", "precipitation", "humidity", "snowDepth"}
+	degreeDayHeader   = []string{"department", "date", "base", "temperature", "value", "cumulative"}
+	sourceHeader      = []string{"department", "source"}
+	revisionHeader    = []string{"department", "date", "temperature", "min", "max", "estimated", "source", "aggregation", "ingestedAt", "revisedAt", "replacedBy"}
+)
+
+func newTemperature(e *storage.TemperatureEntity) *Temperature {
+	return &Temperature{
+		Department:  e.Department,
+		Date:        e.Date.UTC().Format(DateLayout),
+		Temperature: e.Temperature,
+		Min:         e.Min,
+		Max:         e.Max,
+		Estimated:   e.Estimated,
+		Source:      e.Source,
+		Aggregation: e.Aggregation,
+	}
+}
+
+func (t *Temperature) row() []string {
+	return []string{t.Department, t.Date, formatFloat(t.Temperature), formatFloat(t.Min), formatFloat(t.Max),
+		strconv.FormatBool(t.Estimated), t.Source, t.Aggregation}
+}
+
+func newAggregate(a *storage.Aggregate, normal *climate.PeriodNormal) *Aggregate {
+	aggregate := &Aggregate{
+		Department: a.Department,
+		Period:     string(a.Period),
+		Year:       a.Year,
+		Number:     a.Number,
+		From:       a.From.Format(DateLayout),
+		To:         a.To.Format(DateLayout),
+		Mean:       a.Mean,
+		Min:        a.Min,
+		Max:        a.Max,
+		Days:       a.Days,
+		Estimated:  a.Estimated,
+	}
+	if normal != nil {
+		aggregate.Normal = &normal.Normal
+		aggregate.Anomaly = &normal.Deviation
+	}
+	return aggregate
+}
+
+func (a *Aggregate) row() []string {
+	return []string{a.Department, a.Period, strconv.Itoa(a.Year), strconv.Itoa(a.Number), a.From, a.To,
+		formatFloat(a.Mean), formatFloat(a.Min), formatFloat(a.Max), strconv.Itoa(a.Days), strconv.Itoa(a.Estimated),
+		formatOptional(a.Normal), formatOptional(a.Anomaly)}
+}
+
+func newObservation(department string, o *weather.Observation) *Observation {
+	return &Observation{
+		Department:    department,
+		Time:          o.Time.UTC().Format(time.RFC3339),
+		Lat:           o.Coordinates.Lat,
+		Lon:           o.Coordinates.Lon,
+		Temperature:   o.Temperature,
+		WindSpeed:     o.WindSpeed,
+		WindGust:      o.WindGust,
+		Precipitation: o.Precipitation,
+		Humidity:      o.Humidity,
+		SnowDepth:     o.SnowDepth,
+	}
+}
+
+func (o *Observation) row() []string {
+	return []string{o.Department, o.Time, formatFloat(o.Lat), formatFloat(o.Lon), formatFloat(o.Temperature),
+		formatOptional(o.WindSpeed), formatOptional(o.WindGust), formatOptional(o.Precipitation),
+		formatOptional(o.Humidity), formatOptional(o.SnowDepth)}
+}
+
+func newRevision(e *storage.RevisionEntity) *Revision {
+	return &Revision{
+		Department:  e.Department,
+		Date:        e.Date.UTC().Format(DateLayout),
+		Temperature: e.Temperature,
+		Min:         e.Min,
+		Max:         e.Max,
+		Estimated:   e.Estimated,
+		Source:      e.Source,
+		Aggregation: e.Aggregation,
+		IngestedAt:  e.IngestedAt.UTC().Format(time.RFC3339),
+		RevisedAt:   e.RevisedAt.UTC().Format(time.RFC3339),
+		ReplacedBy:  e.ReplacedBy,
+	}
+}
+
+func (r *Revision) row() []string {
+	return []string{r.Department, r.Date, formatFloat(r.Temperature), formatFloat(r.Min), formatFloat(r.Max),
+		strconv.FormatBool(r.Estimated), r.Source, r.Aggregation, r.IngestedAt, r.RevisedAt, r.ReplacedBy}
+}
+
+func newDegreeDay(d *degreedays.Day, base float32) *DegreeDay {
+	return &DegreeDay{
+		Department:  d.Department,
+		Date:        d.Date.Format(DateLayout),
+		Base:        base,
+		Temperature: d.Temperature,
+		Value:       d.Value,
+		Cumulative:  d.Cumulative,
+	}
+}
+
+func (d *DegreeDay) row() []string {
+	return []string{d.Department, d.Date, formatFloat(d.Base), formatFloat(d.Temperature), formatFloat(d.Value), formatFloat(d.Cumulative)}
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	_, err := w.Write(openAPISpec)
+	return err
+}
+
+func (s *Server) departments(w http.ResponseWriter, r *http.Request) error {
+	available := scope(r)
+	departments := make([]*Department, 0, len(s.locations))
+	for _, l := range s.locations {
+		if !allowed(available, l.Description) {
+			continue
+		}
+		coordinates := make([]Coordinate, 0, len(l.Coordinates))
+		for _, c := range l.Coordinates {
+			coordinates = append(coordinates, Coordinate{Lat: c.Lat, Lon: c.Lon})
+		}
+		departments = append(departments, &Department{Name: l.Description, Timezone: l.Timezone, Coordinates: coordinates})
+	}
+	return writeJSON(w, departments)
+}
+
+// temperatures отдает суточные значения построчно, не загружая выборку в память.
+// Общее число строк без учета limit и offset передается в заголовке X-Total-Count.
+func (s *Server) temperatures(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	query := p.query()
+	total, err := s.storage.CountTemperatures(query)
+	if err != nil {
+		return err
+	}
+	it, err := s.storage.IterateTemperatures(query)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if p.format == csvFormat {
+		setCSV(w, "temperatures.csv")
+		out := csv.NewWriter(w)
+		if err := out.Write(temperatureHeader); err != nil {
+			return err
+		}
+		for it.Next() {
+			if err := out.Write(newTemperature(it.Temperature()).row()); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+		return it.Err()
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	for i := 0; it.Next(); i++ {
+		if i > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		item, err := json.Marshal(newTemperature(it.Temperature()))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(item); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write([]byte("]\n")); err != nil {
+		return err
+	}
+	return it.Err()
+}
+
+// aggregates отдает средние и экстремумы за месяц, квартал или год, по умолчанию за месяц,
+// и отклонение средней от климатической нормы, если истории филиала для нормы достаточно.
+func (s *Server) aggregates(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	period := storage.MonthPeriod
+	if value := r.URL.Query().Get("period"); value != "" {
+		if period, err = storage.ParsePeriod(value); err != nil {
+			return badRequest("%s", err)
+		}
+	}
+	entities, err := s.storage.AggregateTemperatures(p.query(), period)
+	if err != nil {
+		return err
+	}
+	normals, err := s.normals.Periods(entities, &p.from, &p.to)
+	if err != nil {
+		return err
+	}
+	aggregates := make([]*Aggregate, 0, len(entities))
+	for _, e := range entities {
+		aggregates = append(aggregates, newAggregate(e, normals[e]))
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0, len(aggregates))
+		for _, a := range aggregates {
+			rows = append(rows, a.row())
+		}
+		return writeCSV(w, "aggregates.csv", aggregateHeader, rows)
+	}
+	return writeJSON(w, aggregates)
+}
+
+// degreeDaysPeriod отдает ГСОП по дням с нарастающим итогом с from по каждому филиалу.
+// limit и offset не применяются: нарастающий итог имеет смысл только для всего периода.
+func (s *Server) degreeDaysPeriod(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	departments := p.departments
+	if len(departments) == 0 {
+		departments = []string{""}
+	}
+	result := make([]*DegreeDay, 0)
+	for _, department := range departments {
+		days, err := s.degreeDays.Period(department, &p.from, &p.to)
+		if err != nil {
+			return err
+		}
+		for _, d := range days {
+			result = append(result, newDegreeDay(d, s.degreeDays.Base()))
+		}
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0, len(result))
+		for _, d := range result {
+			rows = append(rows, d.row())
+		}
+		return writeCSV(w, "degree-days.csv", degreeDayHeader, rows)
+	}
+	return writeJSON(w, result)
+}
+
+// observations отдает почасовые наблюдения по точкам филиалов с 00:00 UTC from до конца суток to по UTC.
+// limit и offset не применяются.
+func (s *Server) observations(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	to := p.to.AddDate(0, 0, 1)
+	result := make([]*Observation, 0)
+	for _, department := range s.departmentNames(p) {
+		observations, err := s.storage.GetObservations(department, &p.from, &to)
+		if err != nil {
+			return err
+		}
+		for i := range observations {
+			result = append(result, newObservation(department, &observations[i]))
+		}
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0, len(result))
+		for _, o := range result {
+			rows = append(rows, o.row())
+		}
+		return writeCSV(w, "observations.csv", observationHeader, rows)
+	}
+	return writeJSON(w, result)
+}
+
+// provenance отдает суточные значения, отобранные по происхождению: source (по вхождению имени провайдера),
+// aggregation, estimated и времени загрузки ingestedFrom, ingestedTo. limit и offset не применяются.
+func (s *Server) provenance(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	filter := storage.ProvenanceFilter{
+		Source:      query.Get("source"),
+		Aggregation: query.Get("aggregation"),
+		From:        &p.from,
+		To:          &p.to,
+	}
+	if filter.Estimated, err = parseBool(query.Get("estimated"), "estimated"); err != nil {
+		return err
+	}
+	if filter.IngestedFrom, err = parseTime(query.Get("ingestedFrom"), "ingestedFrom"); err != nil {
+		return err
+	}
+	if filter.IngestedTo, err = parseTime(query.Get("ingestedTo"), "ingestedTo"); err != nil {
+		return err
+	}
+
+	result := make([]*Temperature, 0)
+	for _, department := range s.departmentNames(p) {
+		filter.Department = department
+		entities, err := s.storage.GetTemperaturesByProvenance(&filter)
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			result = append(result, newTemperature(e))
+		}
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0, len(result))
+		for _, t := range result {
+			rows = append(rows, t.row())
+		}
+		return writeCSV(w, "provenance.csv", temperatureHeader, rows)
+	}
+	return writeJSON(w, result)
+}
+
+// sources отдает провайдеров, от которых получены значения каждого филиала.
+func (s *Server) sources(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseCommon(r)
+	if err != nil {
+		return err
+	}
+	result := make([]*Sources, 0)
+	for _, department := range s.departmentNames(p) {
+		sources, err := s.storage.GetSources(department)
+		if err != nil {
+			return err
+		}
+		result = append(result, &Sources{Department: department, Sources: sources})
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0)
+		for _, item := range result {
+			for _, source := range item.Sources {
+				rows = append(rows, []string{item.Department, source})
+			}
+		}
+		return writeCSV(w, "sources.csv", sourceHeader, rows)
+	}
+	return writeJSON(w, result)
+}
+
+// revisions отдает прежние суточные значения, замененные при повторной загрузке, в порядке замены.
+// limit и offset не применяются.
+func (s *Server) revisions(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.parseParams(r)
+	if err != nil {
+		return err
+	}
+	result := make([]*Revision, 0)
+	for _, department := range s.departmentNames(p) {
+		entities, err := s.storage.GetRevisions(department, &p.from, &p.to)
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			result = append(result, newRevision(e))
+		}
+	}
+
+	if p.format == csvFormat {
+		rows := make([][]string, 0, len(result))
+		for _, item := range result {
+			rows = append(rows, item.row())
+		}
+		return writeCSV(w, "revisions.csv", revisionHeader, rows)
+	}
+	return writeJSON(w, result)
+}
+
+func setCSV(w http.ResponseWriter, filename string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) error {
+	setCSV(w, filename)
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+func formatOptional(v *float32) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
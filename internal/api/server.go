@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/storage"
+	"temperature/internal/weather"
+	"time"
+)
+
+// DateLayout - формат дат в параметрах запросов и ответах.
+const DateLayout = "2006-01-02"
+
+const (
+	jsonFormat = "json"
+	csvFormat  = "csv"
+)
+
+// Server отдает сохраненные данные по HTTP другим сервисам, чтобы они не читали БД напрямую.
+// Данные доступны только по ключу и только по филиалам, разрешенным ключу.
+type Server struct {
+	storage    storage.Storage
+	locations  []weather.Location
+	degreeDays *degreedays.Calculator
+	normals    *climate.Normals
+	limiter    *limiter
+}
+
+func NewServer(s storage.Storage, locations []weather.Location, degreeDays *degreedays.Calculator, normals *climate.Normals) *Server {
+	return &Server{
+		storage:    s,
+		locations:  locations,
+		degreeDays: degreeDays,
+		normals:    normals,
+		limiter:    newLimiter(),
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/openapi.yaml", get(s.openAPI))
+	mux.HandleFunc("/api/v1/departments", get(s.authorized(s.departments)))
+	mux.HandleFunc("/api/v1/temperatures", get(s.authorized(s.temperatures)))
+	mux.HandleFunc("/api/v1/aggregates", get(s.authorized(s.aggregates)))
+	mux.HandleFunc("/api/v1/degree-days", get(s.authorized(s.degreeDaysPeriod)))
+	mux.HandleFunc("/api/v1/observations", get(s.authorized(s.observations)))
+	mux.HandleFunc("/api/v1/provenance", get(s.authorized(s.provenance)))
+	mux.HandleFunc("/api/v1/sources", get(s.authorized(s.sources)))
+	mux.HandleFunc("/api/v1/revisions", get(s.authorized(s.revisions)))
+	return mux
+}
+
+// httpError - ошибка запроса, которая возвращается клиенту с указанным статусом.
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// get оборачивает обработчик: разрешает только GET и превращает ошибку в JSON-ответ.
+func get(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, &httpError{status: http.StatusMethodNotAllowed, message: "Метод не поддерживается"})
+			return
+		}
+		rw := &responseWriter{ResponseWriter: w}
+		if err := handler(rw, r); err != nil {
+			if _, ok := err.(*httpError); !ok || rw.started {
+				log.WithFields(log.Fields{
+					"path":  r.URL.Path,
+					"query": r.URL.RawQuery,
+				}).Errorf("Ошибка обработки запроса: %s", err)
+			}
+			// Статус и начало ответа уже отправлены, ошибку в тело дописывать нельзя.
+			if rw.started {
+				return
+			}
+			writeError(w, err)
+		}
+	}
+}
+
+// responseWriter запоминает, начата ли отправка ответа.
+type responseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "Внутренняя ошибка сервера"
+	if e, ok := err.(*httpError); ok {
+		status = e.status
+		message = e.message
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// params - разобранные общие параметры запроса.
+type params struct {
+	departments []string
+	from        time.Time
+	to          time.Time
+	limit       int
+	offset      int
+	format      string
+}
+
+// parseParams разбирает department (можно повторять или перечислять через запятую), from, to, limit, offset и format.
+// from и to обязательны. Без department выбираются все филиалы, доступные ключу.
+func (s *Server) parseParams(r *http.Request) (*params, error) {
+	p, err := s.parseCommon(r)
+	if err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	if p.from, err = parseDate(query.Get("from"), "from"); err != nil {
+		return nil, err
+	}
+	if p.to, err = parseDate(query.Get("to"), "to"); err != nil {
+		return nil, err
+	}
+	if p.to.Before(p.from) {
+		return nil, badRequest("Дата окончания раньше даты начала")
+	}
+	if p.limit, err = parseCount(query.Get("limit"), "limit"); err != nil {
+		return nil, err
+	}
+	if p.offset, err = parseCount(query.Get("offset"), "offset"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseCommon разбирает только department и format - параметры запросов без периода.
+func (s *Server) parseCommon(r *http.Request) (*params, error) {
+	query := r.URL.Query()
+	p := &params{format: jsonFormat}
+	available := scope(r)
+
+	for _, value := range query["department"] {
+		for _, department := range strings.Split(value, ",") {
+			if department = strings.TrimSpace(department); department == "" {
+				continue
+			}
+			if !s.known(department) {
+				return nil, badRequest("Неизвестный филиал: %s", department)
+			}
+			if !allowed(available, department) {
+				return nil, forbidden(department)
+			}
+			p.departments = append(p.departments, department)
+		}
+	}
+	if len(p.departments) == 0 {
+		p.departments = available
+	}
+
+	if format := query.Get("format"); format != "" {
+		if format != jsonFormat && format != csvFormat {
+			return nil, badRequest("Неизвестный формат: %s", format)
+		}
+		p.format = format
+	}
+	return p, nil
+}
+
+func (p *params) query() *storage.TemperatureQuery {
+	return &storage.TemperatureQuery{
+		Departments: p.departments,
+		From:        &p.from,
+		To:          &p.to,
+		Limit:       p.limit,
+		Offset:      p.offset,
+	}
+}
+
+// departmentNames возвращает запрошенные филиалы, а если они не указаны и ключу доступны все, - все настроенные.
+func (s *Server) departmentNames(p *params) []string {
+	if len(p.departments) > 0 {
+		return p.departments
+	}
+	names := make([]string, 0, len(s.locations))
+	for _, l := range s.locations {
+		names = append(names, l.Description)
+	}
+	return names
+}
+
+func (s *Server) known(department string) bool {
+	for _, l := range s.locations {
+		if l.Description == department {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDate(value string, name string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, badRequest("Не указан параметр %s", name)
+	}
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, badRequest("Неверный формат даты в параметре %s, ожидается %s", name, DateLayout)
+	}
+	return date, nil
+}
+
+func parseTime(value string, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, badRequest("Неверный формат времени в параметре %s, ожидается RFC 3339", name)
+	}
+	return &t, nil
+}
+
+func parseBool(value string, name string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, badRequest("Параметр %s должен быть true или false", name)
+	}
+	return &b, nil
+}
+
+func parseCount(value string, name string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, badRequest("Параметр %s должен быть неотрицательным целым числом", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetWritesErrorBeforeResponse(t *testing.T) {
+	handler := get(func(w http.ResponseWriter, r *http.Request) error {
+		return badRequest("Неверный параметр")
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/temperatures", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if body := w.Body.String(); body != "{\"error\":\"Неверный параметр\"}\n" {
+		t.Errorf("got body %q", body)
+	}
+}
+
+func TestGetStopsAfterStreamingStarted(t *testing.T) {
+	handler := get(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.Write([]byte("[{\"department\":\"Чита\"}")); err != nil {
+			return err
+		}
+		return errors.New("connection reset")
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/temperatures", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); body != "[{\"department\":\"Чита\"}" {
+		t.Errorf("got body %q, want only the streamed rows", body)
+	}
+}
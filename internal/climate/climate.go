@@ -0,0 +1,150 @@
+package climate
+
+import (
+	"math"
+	"temperature/internal/storage"
+	"time"
+)
+
+const (
+	// DefaultExtremeSigmas - отклонение от нормы в сигмах, начиная с которого день считается экстремальным.
+	DefaultExtremeSigmas float32 = 2
+	// DefaultMinYears - минимальное число лет истории для расчета нормы.
+	DefaultMinYears = 3
+)
+
+type Anomaly struct {
+	Department  string
+	Date        time.Time
+	Temperature float32
+	Normal      float32
+	Std         float32
+	Deviation   float32
+	Sigmas      float32
+	Extreme     bool
+}
+
+// PeriodNormal - норма за период и отклонение от нее средней температуры периода.
+type PeriodNormal struct {
+	Normal    float32
+	Deviation float32
+}
+
+type normalKey struct {
+	department string
+	month      int
+	day        int
+}
+
+// Normals сравнивает суточные значения с климатической нормой, рассчитанной по истории филиала.
+type Normals struct {
+	storage       storage.Storage
+	extremeSigmas float32
+	minYears      int
+}
+
+func New(s storage.Storage, extremeSigmas float32, minYears int) *Normals {
+	return &Normals{
+		storage:       s,
+		extremeSigmas: extremeSigmas,
+		minYears:      minYears,
+	}
+}
+
+// Anomalies возвращает отклонения от нормы для значений филиалов за date.
+// Филиалы, для которых истории недостаточно, в результат не попадают.
+func (n *Normals) Anomalies(date *time.Time, temperatures map[string]float32) (map[string]*Anomaly, error) {
+	normals, err := n.storage.GetNormals("", date.Year())
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[normalKey]*storage.Normal, len(normals))
+	for _, normal := range normals {
+		byDay[normalKey{normal.Department, normal.Month, normal.Day}] = normal
+	}
+
+	anomalies := make(map[string]*Anomaly, len(temperatures))
+	for department, temperature := range temperatures {
+		normal, ok := byDay[normalKey{department, int(date.Month()), date.Day()}]
+		if !ok || normal.Years < n.minYears {
+			continue
+		}
+		anomalies[department] = n.anomaly(date, temperature, normal)
+	}
+	return anomalies, nil
+}
+
+func (n *Normals) anomaly(date *time.Time, temperature float32, normal *storage.Normal) *Anomaly {
+	deviation := temperature - normal.Mean
+	var sigmas float32
+	if normal.Std > 0 {
+		sigmas = deviation / normal.Std
+	}
+	return &Anomaly{
+		Department:  normal.Department,
+		Date:        *date,
+		Temperature: temperature,
+		Normal:      normal.Mean,
+		Std:         normal.Std,
+		Deviation:   deviation,
+		Sigmas:      sigmas,
+		Extreme:     float32(math.Abs(float64(sigmas))) >= n.extremeSigmas,
+	}
+}
+
+// Periods возвращает норму и отклонение от нее для средних за периоды. Норма периода - среднее
+// дневных норм его дней внутри [from, to], год периода в норму не входит. Периоды, для дней
+// которых истории недостаточно, в результат не попадают. 29 февраля пропускается, если по нему
+// недостаточно истории.
+func (n *Normals) Periods(aggregates []*storage.Aggregate, from *time.Time, to *time.Time) (map[*storage.Aggregate]*PeriodNormal, error) {
+	type historyKey struct {
+		department string
+		year       int
+	}
+	history := make(map[historyKey]map[normalKey]*storage.Normal)
+	result := make(map[*storage.Aggregate]*PeriodNormal, len(aggregates))
+	for _, a := range aggregates {
+		key := historyKey{a.Department, a.Year}
+		byDay, ok := history[key]
+		if !ok {
+			normals, err := n.storage.GetNormals(a.Department, a.Year)
+			if err != nil {
+				return nil, err
+			}
+			byDay = make(map[normalKey]*storage.Normal, len(normals))
+			for _, normal := range normals {
+				byDay[normalKey{normal.Department, normal.Month, normal.Day}] = normal
+			}
+			history[key] = byDay
+		}
+
+		start, end := a.From, a.To
+		if from != nil && from.After(start) {
+			start = *from
+		}
+		if to != nil && to.Before(end) {
+			end = *to
+		}
+		var sum float32
+		days := 0
+		complete := true
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+			normal, ok := byDay[normalKey{a.Department, int(date.Month()), date.Day()}]
+			if !ok || normal.Years < n.minYears {
+				if date.Month() == time.February && date.Day() == 29 {
+					continue
+				}
+				complete = false
+				break
+			}
+			sum += normal.Mean
+			days++
+		}
+		if !complete || days == 0 {
+			continue
+		}
+		normal := sum / float32(days)
+		result[a] = &PeriodNormal{Normal: normal, Deviation: a.Mean - normal}
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package degreedays
+
+import (
+	"temperature/internal/storage"
+	"time"
+)
+
+// DefaultBase - базовая температура внутреннего воздуха по умолчанию, °C.
+const DefaultBase float32 = 18
+
+type Day struct {
+	Department  string
+	Date        time.Time
+	Temperature float32
+	Value       float32
+	Cumulative  float32
+}
+
+// Calculator рассчитывает градусо-сутки отопительного периода (ГСОП) по суточным температурам филиалов.
+// ГСОП считаются при чтении, поэтому дозагруженные, восстановленные и перезаписанные температуры
+// и смена базовой температуры учитываются сразу.
+type Calculator struct {
+	storage storage.Storage
+	base    float32
+}
+
+func New(s storage.Storage, base float32) *Calculator {
+	return &Calculator{
+		storage: s,
+		base:    base,
+	}
+}
+
+func (c *Calculator) Base() float32 {
+	return c.base
+}
+
+// Daily - градусо-сутки за один день: на сколько среднесуточная температура ниже базовой.
+func Daily(temperature float32, base float32) float32 {
+	if temperature >= base {
+		return 0
+	}
+	return base - temperature
+}
+
+// Period возвращает градусо-сутки по дням за [from, to] по сохраненным температурам.
+// Пустой department означает все филиалы.
+// Cumulative - нарастающий итог с начала периода отдельно по каждому филиалу.
+func (c *Calculator) Period(department string, from *time.Time, to *time.Time) ([]*Day, error) {
+	temps, err := c.storage.GetTemperatures(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]float32)
+	days := make([]*Day, 0, len(temps))
+	for _, t := range temps {
+		value := Daily(t.Temperature, c.base)
+		totals[t.Department] += value
+		days = append(days, &Day{
+			Department:  t.Department,
+			Date:        t.Date.UTC(),
+			Temperature: t.Temperature,
+			Value:       value,
+			Cumulative:  totals[t.Department],
+		})
+	}
+	return days, nil
+}
+
+// Total возвращает сумму градусо-суток за [from, to] по каждому филиалу.
+func (c *Calculator) Total(department string, from *time.Time, to *time.Time) (map[string]float32, error) {
+	days, err := c.Period(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]float32)
+	for _, d := range days {
+		totals[d.Department] = d.Cumulative
+	}
+	return totals, nil
+}
+
+// MonthTotal возвращает сумму градусо-суток с начала месяца по date включительно.
+func (c *Calculator) MonthTotal(department string, date *time.Time) (map[string]float32, error) {
+	from := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return c.Total(department, &from, date)
+}
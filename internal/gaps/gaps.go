@@ -0,0 +1,198 @@
+package gaps
+
+import (
+	"fmt"
+	"temperature/internal/storage"
+	"temperature/internal/weather"
+	"time"
+)
+
+type Method string
+
+const (
+	LinearMethod    Method = "linear"
+	NeighbourMethod Method = "neighbour"
+)
+
+// DefaultMaxDays - максимальная длина пропуска, который допускается восстанавливать.
+const DefaultMaxDays = 3
+
+// offsetWindow - сколько дней до и после пропуска используется для расчета разницы с соседним филиалом.
+const offsetWindow = 7
+
+var ErrNoReference = fmt.Errorf("Недостаточно данных для восстановления пропуска")
+
+func ParseMethod(s string) (Method, error) {
+	switch method := Method(s); method {
+	case LinearMethod, NeighbourMethod:
+		return method, nil
+	default:
+		return "", fmt.Errorf("Неизвестный метод восстановления: %s", s)
+	}
+}
+
+// Filler восстанавливает короткие пропуски. Восстановленные значения сохраняются с признаком Estimated.
+type Filler struct {
+	storage   storage.Storage
+	locations []weather.Location
+}
+
+func NewFiller(s storage.Storage, locations []weather.Location) *Filler {
+	return &Filler{
+		storage:   s,
+		locations: locations,
+	}
+}
+
+func (f *Filler) Fill(gap *storage.Gap, method Method) ([]*storage.TemperatureEntity, error) {
+	var entities []*storage.TemperatureEntity
+	var err error
+	switch method {
+	case NeighbourMethod:
+		entities, err = f.neighbour(gap)
+	default:
+		entities, err = f.linear(gap)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := f.storage.SaveTemperatures(entities); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// linear интерполирует значения между последним днем до пропуска и первым днем после него.
+func (f *Filler) linear(gap *storage.Gap) ([]*storage.TemperatureEntity, error) {
+	before := gap.From.AddDate(0, 0, -1)
+	after := gap.To.AddDate(0, 0, 1)
+	left, err := f.day(gap.Department, &before)
+	if err != nil {
+		return nil, err
+	}
+	right, err := f.day(gap.Department, &after)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, ErrNoReference
+	}
+
+	steps := float32(gap.Days() + 1)
+	entities := make([]*storage.TemperatureEntity, 0, gap.Days())
+	for i, date := 1, gap.From; !date.After(gap.To); i, date = i+1, date.AddDate(0, 0, 1) {
+		k := float32(i) / steps
+		entities = append(entities, estimated(gap.Department, date, string(LinearMethod), left.Aggregation,
+			left.Temperature+(right.Temperature-left.Temperature)*k,
+			left.Min+(right.Min-left.Min)*k,
+			left.Max+(right.Max-left.Max)*k,
+		))
+	}
+	return entities, nil
+}
+
+// neighbour берет значения ближайшего филиала с данными за все дни пропуска
+// и сдвигает их на среднюю разницу между филиалами в соседние с пропуском дни.
+func (f *Filler) neighbour(gap *storage.Gap) ([]*storage.TemperatureEntity, error) {
+	target := f.location(gap.Department)
+	if target == nil {
+		return nil, fmt.Errorf("Филиал не найден в конфигурации: %s", gap.Department)
+	}
+	from := gap.From.AddDate(0, 0, -offsetWindow)
+	to := gap.To.AddDate(0, 0, offsetWindow)
+	own, err := f.series(gap.Department, &from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, neighbour := range target.Nearest(f.locations) {
+		other, err := f.series(neighbour.Description, &from, &to)
+		if err != nil {
+			return nil, err
+		}
+		offset, ok := meanOffset(own, other)
+		if !ok {
+			continue
+		}
+
+		entities := make([]*storage.TemperatureEntity, 0, gap.Days())
+		for date := gap.From; !date.After(gap.To); date = date.AddDate(0, 0, 1) {
+			t, ok := other[date]
+			if !ok {
+				break
+			}
+			entities = append(entities, estimated(gap.Department, date, string(NeighbourMethod)+":"+neighbour.Description, t.Aggregation,
+				t.Temperature+offset.Temperature, t.Min+offset.Min, t.Max+offset.Max))
+		}
+		if len(entities) == gap.Days() {
+			return entities, nil
+		}
+	}
+	return nil, ErrNoReference
+}
+
+func (f *Filler) day(department string, date *time.Time) (*storage.TemperatureEntity, error) {
+	temps, err := f.storage.GetTemperatures(department, date, date)
+	if err != nil || len(temps) == 0 {
+		return nil, err
+	}
+	return temps[0], nil
+}
+
+func (f *Filler) series(department string, from *time.Time, to *time.Time) (map[time.Time]*storage.TemperatureEntity, error) {
+	temps, err := f.storage.GetTemperatures(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[time.Time]*storage.TemperatureEntity, len(temps))
+	for _, t := range temps {
+		series[t.Date.UTC()] = t
+	}
+	return series, nil
+}
+
+func (f *Filler) location(department string) *weather.Location {
+	for i := range f.locations {
+		if f.locations[i].Description == department {
+			return &f.locations[i]
+		}
+	}
+	return nil
+}
+
+func meanOffset(own map[time.Time]*storage.TemperatureEntity, other map[time.Time]*storage.TemperatureEntity) (*storage.TemperatureEntity, bool) {
+	offset := storage.TemperatureEntity{}
+	n := 0
+	for date, t := range own {
+		o, ok := other[date]
+		if !ok || t.Estimated || o.Estimated {
+			continue
+		}
+		offset.Temperature += t.Temperature - o.Temperature
+		offset.Min += t.Min - o.Min
+		offset.Max += t.Max - o.Max
+		n++
+	}
+	if n == 0 {
+		return nil, false
+	}
+	offset.Temperature /= float32(n)
+	offset.Min /= float32(n)
+	offset.Max /= float32(n)
+	return &offset, true
+}
+
+// estimated создает восстановленное значение. В Source записывается метод восстановления.
+func estimated(department string, date time.Time, method string, aggregation string, temperature float32, min float32, max float32) *storage.TemperatureEntity {
+	return &storage.TemperatureEntity{
+		Temperature: temperature,
+		Min:         min,
+		Max:         max,
+		Department:  department,
+		Date:        date,
+		Estimated:   true,
+		Source:      "estimated:" + method,
+		IngestedAt:  time.Now().UTC(),
+		Aggregation: aggregation,
+	}
+}
@@ -0,0 +1,87 @@
+package heating
+
+import (
+	"temperature/internal/storage"
+	"time"
+)
+
+// Значения по умолчанию по правилам предоставления коммунальных услуг:
+// отопительный период начинается после 5 суток подряд со среднесуточной температурой ниже +8°C
+// и заканчивается после 5 суток подряд выше +8°C.
+const (
+	DefaultThreshold float32 = 8
+	DefaultDays              = 5
+)
+
+type Change struct {
+	Department string
+	Active     bool
+	Date       time.Time
+	Threshold  float32
+	Days       int
+}
+
+type Detector struct {
+	storage   storage.Storage
+	threshold float32
+	days      int
+}
+
+func NewDetector(s storage.Storage, threshold float32, days int) *Detector {
+	return &Detector{
+		storage:   s,
+		threshold: threshold,
+		days:      days,
+	}
+}
+
+// Detect проверяет последние days суток филиала по date включительно и сохраняет новое состояние.
+// Возвращает nil, если отопительный период не начался и не закончился.
+// Если данных хотя бы за один из дней нет, решение не принимается.
+// Первое состояние филиала только запоминается: неизвестно, когда период на самом деле начался или закончился.
+func (d *Detector) Detect(department string, date *time.Time) (*Change, error) {
+	from := date.AddDate(0, 0, -(d.days - 1))
+	temps, err := d.storage.GetTemperatures(department, &from, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(temps) < d.days {
+		return nil, nil
+	}
+
+	cold, warm := true, true
+	for _, t := range temps {
+		cold = cold && t.Temperature < d.threshold
+		warm = warm && t.Temperature > d.threshold
+	}
+	if !cold && !warm {
+		return nil, nil
+	}
+
+	state, err := d.storage.GetHeatingSeason(department)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && (cold == state.Active || state.Since.After(*date)) {
+		return nil, nil
+	}
+
+	err = d.storage.SaveHeatingSeason(&storage.HeatingSeasonEntity{
+		Department: department,
+		Active:     cold,
+		Since:      *date,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, nil
+	}
+	return &Change{
+		Department: department,
+		Active:     cold,
+		Date:       *date,
+		Threshold:  d.threshold,
+		Days:       d.days,
+	}, nil
+}
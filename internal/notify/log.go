@@ -0,0 +1,13 @@
+package notify
+
+import log "github.com/sirupsen/logrus"
+
+type LogNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Emit(message string) {
+	log.WithField("module", "log-notifier").Warn(message)
+}
@@ -7,8 +7,12 @@ import (
 	log "github.com/sirupsen/logrus"
 	"strings"
 	"sync"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/heating"
 	"temperature/internal/notify"
 	"temperature/internal/storage"
+	"temperature/internal/validation"
 	weather "temperature/internal/weather"
 	"time"
 )
@@ -18,7 +22,12 @@ var logger = log.New()
 type Scrapper struct {
 	config     *Config
 	weatherAPI *weather.API
+	sources    weather.RecordingSource
 	storage    storage.Storage
+	degreeDays *degreedays.Calculator
+	season     *heating.Detector
+	normals    *climate.Normals
+	validator  *validation.Validator
 	cron       *cron.Cron
 	notifier   notify.Notifier
 }
@@ -28,18 +37,29 @@ func New(c *Config) *Scrapper {
 		config: c,
 		cron:   cron.New(cron.WithLocation(time.UTC)),
 	}
+	app.initNotifier()
 	app.initWeatherAPI()
 	app.initStorage()
+	app.initDegreeDays()
+	app.initSeasonDetector()
+	app.initNormals()
+	app.initValidator()
 	app.initCron()
-	app.initNotifier()
 	return &app
 }
 
 func (scrapper *Scrapper) initWeatherAPI() {
 	logger.Info("Инициализация модуля погоды")
-	source := weather.NewOpenWeatherAPI(&scrapper.config.Weather.Token)
-	//source := sources.FakeSource{}
-	api, err := weather.New(source)
+	sources, err := NewWeatherSource(&scrapper.config.Weather, scrapper.notifier)
+	if err != nil {
+		logger.Fatalf("Ошибка инициализации модуля погоды : %s", err)
+	}
+	scrapper.sources = sources
+	aggregation, err := weather.ParseAggregation(scrapper.config.Weather.Aggregation)
+	if err != nil {
+		logger.Fatalf("Ошибка инициализации модуля погоды : %s", err)
+	}
+	api, err := weather.New(scrapper.sources, aggregation)
 	if err != nil {
 		logger.Fatalf("Ошибка инициализации модуля погоды : %s", err)
 	}
@@ -49,16 +69,25 @@ func (scrapper *Scrapper) initWeatherAPI() {
 
 func (scrapper *Scrapper) initStorage() {
 	logger.Info("Инициализация модуля storage")
-	repo := storage.New(&scrapper.config.DBPath)
-	err := repo.Init()
+	storage, err := scrapper.config.OpenStorage()
 	if err != nil {
 		logger.Fatalf("Repository init error: %s", err)
 	}
-	storage := storage.NewDBStorage(repo)
 	scrapper.storage = storage
 	logger.Info("Модуль storage инициализирован")
 }
 
+func (scrapper *Scrapper) initDegreeDays() {
+	scrapper.degreeDays = degreedays.New(scrapper.storage, scrapper.config.DegreeDays.BaseTemperature())
+	logger.WithField("база", scrapper.degreeDays.Base()).Info("Модуль ГСОП инициализирован")
+}
+
+func (scrapper *Scrapper) initNormals() {
+	config := scrapper.config.Climate
+	scrapper.normals = climate.New(scrapper.storage, config.ExtremeThreshold(), config.MinHistoryYears())
+	logger.Info("Модуль климатических норм инициализирован")
+}
+
 func (scrapper *Scrapper) initCron() {
 	logger.Info("Инициализация модуля cron")
 	_, err := scrapper.cron.AddFunc(scrapper.config.Schedule, func() {
@@ -69,12 +98,14 @@ func (scrapper *Scrapper) initCron() {
 			scrapper.notifier.Emit(newErrorUpdateMessage())
 		} else {
 			logger.Info("Данные о погоде получены")
-			scrapper.notifier.Emit(*newSuccessUpdateMessage(results))
+			scrapper.notifier.Emit(*newSuccessUpdateMessage(results, scrapper.monthDegreeDays(results), scrapper.anomalies(results)))
+			scrapper.detectSeasons(results)
 		}
 	})
 	if err != nil {
 		logger.Fatalf("Модуль cron завершился с ошибкой: %s", err)
 	}
+	scrapper.initForecastCron()
 	logger.Info("Модуль cron инициализирован")
 }
 
@@ -93,57 +124,104 @@ func (scrapper *Scrapper) Run() {
 	scrapper.cron.Start()
 }
 
-func (scrapper *Scrapper) update() ([]*weather.Temperature, error) {
+func (scrapper *Scrapper) update() ([]*dayResult, error) {
+	plan, err := scrapper.planUpdate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*dayResult, 0, len(plan.dates))
+	for _, date := range plan.dates {
+		temperatures, err := scrapper.updateDate(&date, plan.locations[date])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &dayResult{date: date, temperatures: temperatures})
+	}
+	return results, nil
+}
+
+// monthDegreeDays возвращает ГСОП с начала месяца по последний загруженный день.
+func (scrapper *Scrapper) monthDegreeDays(results []*dayResult) map[string]float32 {
+	if len(results) == 0 {
+		return nil
+	}
+	last := results[len(results)-1].date
+	totals, err := scrapper.degreeDays.MonthTotal("", &last)
+	if err != nil {
+		logger.Errorf("Не удалось получить ГСОП за месяц: %s", err)
+		return nil
+	}
+	return totals
+}
+
+// locationResult - результат запроса температуры по одному филиалу.
+type locationResult struct {
+	temperature *weather.Temperature
+	err         error
+}
+
+func (scrapper *Scrapper) updateDate(date *time.Time, locations []weather.Location) ([]*weather.Temperature, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	errorChan := make(chan error)
-	defer close(errorChan)
-	outChan := scrapper.getTemperatures(ctx, errorChan)
 	results := make([]*weather.Temperature, 0, 10)
 
-EXIT:
-	for {
-		select {
-		case r, ok := <-outChan:
-			if !ok {
-				break EXIT
+	// Канал закрывается после завершения всех запросов, поэтому при ошибке запросы
+	// отменяются, а канал дочитывается до конца.
+	var err error
+	for r := range scrapper.getTemperatures(ctx, date, locations) {
+		if r.err != nil {
+			if err == nil {
+				err = r.err
+				cancel()
 			}
-			results = append(results, r)
-			logger.WithFields(log.Fields{
-				"филиал":      r.Location.Description,
-				"температура": r.Value,
-			}).Info("Получено значение")
-		case err := <-errorChan:
-			return nil, err
+			continue
+		}
+		if err != nil {
+			continue
 		}
+		results = append(results, r.temperature)
+		logger.WithFields(log.Fields{
+			"дата":        date.Format("02.01.2006"),
+			"филиал":      r.temperature.Location.Description,
+			"температура": r.temperature.Value,
+			"мин":         r.temperature.Min,
+			"макс":        r.temperature.Max,
+			"источники":   AnsweredBy(scrapper.sources, r.temperature.Location),
+		}).Info("Получено значение")
+	}
+	if err != nil {
+		return nil, err
 	}
-	if err := scrapper.storage.SaveTemperatureByLastDay(results); err != nil {
+	valid, _, err := Validate(scrapper.validator, scrapper.notifier, date, results)
+	if err != nil {
 		return nil, err
 	}
-	return results, nil
+	if err := scrapper.storage.SaveTemperatureByDate(date, valid); err != nil {
+		return nil, err
+	}
+	return valid, nil
 }
 
-func (scrapper *Scrapper) getTemperatures(ctx context.Context, errors chan<- error) chan *weather.Temperature {
-	results := make(chan *weather.Temperature)
+func (scrapper *Scrapper) getTemperatures(ctx context.Context, date *time.Time, locations []weather.Location) <-chan locationResult {
+	results := make(chan locationResult, len(locations))
 
 	wg := sync.WaitGroup{}
-
+	for _, location := range locations {
+		wg.Add(1)
+		go func(location weather.Location) {
+			defer wg.Done()
+			temp, err := scrapper.weatherAPI.TemperatureOfDateByLocation(ctx, date, &location)
+			if err != nil {
+				results <- locationResult{err: err}
+				return
+			}
+			t := weather.NewTemperature(&location, temp)
+			t.Source = strings.Join(AnsweredBy(scrapper.sources, &location), ",")
+			results <- locationResult{temperature: t}
+		}(location)
+	}
 	go func() {
-		for _, location := range scrapper.config.Locations {
-			wg.Add(1)
-			go func(location weather.Location) {
-				temp, err := scrapper.weatherAPI.TemperatureOfDayByLocation(ctx, &location)
-				if err != nil {
-					errors <- err
-				} else {
-					results <- &weather.Temperature{
-						Location: &location,
-						Value:    temp,
-					}
-				}
-				wg.Done()
-			}(location)
-		}
 		wg.Wait()
 		close(results)
 	}()
@@ -151,11 +229,63 @@ func (scrapper *Scrapper) getTemperatures(ctx context.Context, errors chan<- err
 	return results
 }
 
-func newSuccessUpdateMessage(results []*weather.Temperature) *string {
+// AnsweredBy возвращает провайдеров, ответивших по точкам филиала. Из них складывается источник суточного значения.
+func AnsweredBy(source weather.RecordingSource, location *weather.Location) []string {
+	providers := make([]string, 0, len(location.Coordinates))
+	for _, coordinates := range location.Coordinates {
+		if name, ok := source.AnsweredBy(&coordinates); ok {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
+
+// anomalies возвращает отклонения от климатической нормы за последний загруженный день.
+func (scrapper *Scrapper) anomalies(results []*dayResult) map[string]*climate.Anomaly {
+	if len(results) == 0 {
+		return nil
+	}
+	last := results[len(results)-1]
+	temperatures := make(map[string]float32, len(last.temperatures))
+	for _, t := range last.temperatures {
+		temperatures[t.Location.Description] = t.Value
+	}
+	anomalies, err := scrapper.normals.Anomalies(&last.date, temperatures)
+	if err != nil {
+		logger.Errorf("Не удалось рассчитать отклонение от нормы: %s", err)
+		return nil
+	}
+	return anomalies
+}
+
+func newSuccessUpdateMessage(results []*dayResult, degreeDays map[string]float32, anomalies map[string]*climate.Anomaly) *string {
 	builder := strings.Builder{}
+	if len(results) == 0 {
+		builder.WriteString("Обновление прошло успешно! Новых данных нет")
+		message := builder.String()
+		return &message
+	}
 	builder.WriteString("Обновление прошло успешно! \n\r")
-	for _, item := range results {
-		builder.WriteString(fmt.Sprintf("%s - %0.1fC; \n\r", item.Location.Description, item.Value))
+	last := results[len(results)-1]
+	for _, item := range last.temperatures {
+		if anomaly, ok := anomalies[item.Location.Description]; ok && anomaly.Extreme {
+			builder.WriteString("‼ ")
+		}
+		builder.WriteString(fmt.Sprintf("%s - %0.1fC", item.Location.Description, item.Value))
+		if anomaly, ok := anomalies[item.Location.Description]; ok {
+			builder.WriteString(fmt.Sprintf(", норма %0.1fC, отклонение %+0.1fC (%+0.1fσ)", anomaly.Normal, anomaly.Deviation, anomaly.Sigmas))
+		}
+		if total, ok := degreeDays[item.Location.Description]; ok {
+			builder.WriteString(fmt.Sprintf(", ГСОП с начала месяца %0.1f", total))
+		}
+		builder.WriteString("; \n\r")
+	}
+	if len(results) > 1 {
+		dates := make([]string, 0, len(results)-1)
+		for _, result := range results[:len(results)-1] {
+			dates = append(dates, result.date.Format("02.01.2006"))
+		}
+		builder.WriteString(fmt.Sprintf("Загружены пропущенные дни: %s", strings.Join(dates, ", ")))
 	}
 	message := builder.String()
 	return &message
@@ -0,0 +1,93 @@
+package scrapper
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"temperature/internal/weather"
+	"time"
+)
+
+const defaultBackfillDays = 30
+
+// depthMargin - запас к глубине источника, чтобы день не устарел, пока идет загрузка.
+const depthMargin = time.Hour
+
+type dayResult struct {
+	date         time.Time
+	temperatures []*weather.Temperature
+}
+
+type updatePlan struct {
+	dates     []time.Time
+	locations map[time.Time][]weather.Location
+}
+
+func (p *updatePlan) add(date time.Time, location weather.Location) {
+	if _, ok := p.locations[date]; !ok {
+		p.dates = append(p.dates, date)
+	}
+	p.locations[date] = append(p.locations[date], location)
+}
+
+// planUpdate для каждого филиала находит дни после последней сохраненной даты,
+// ограничивая глубину возможностями источника погоды и настройкой maxBackfillDays.
+// Вчерашний день определяется по часовому поясу филиала.
+func (scrapper *Scrapper) planUpdate(now time.Time) (*updatePlan, error) {
+	lastDates, err := scrapper.storage.GetLastDates()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := updatePlan{locations: make(map[time.Time][]weather.Location)}
+	for _, location := range scrapper.config.Locations {
+		loc, err := location.TimeLocation()
+		if err != nil {
+			return nil, err
+		}
+		yesterday := truncateDay(now.In(loc).AddDate(0, 0, -1))
+		earliest := scrapper.earliestDate(now, yesterday, loc)
+
+		from := yesterday
+		if last, ok := lastDates[location.Description]; ok {
+			from = truncateDay(last).AddDate(0, 0, 1)
+			if from.Before(earliest) {
+				logger.WithFields(log.Fields{
+					"филиал": location.Description,
+					"с":      from.Format("02.01.2006"),
+					"по":     earliest.AddDate(0, 0, -1).Format("02.01.2006"),
+				}).Warn("Пропущенные дни превышают глубину источника и не будут загружены")
+				from = earliest
+			}
+		}
+		for date := from; !date.After(yesterday); date = date.AddDate(0, 0, 1) {
+			plan.add(date, location)
+		}
+	}
+	sort.Slice(plan.dates, func(i, j int) bool { return plan.dates[i].Before(plan.dates[j]) })
+	return &plan, nil
+}
+
+// earliestDate возвращает самый ранний день, который еще можно загрузить. Источник проверяет
+// глубину по началу суток филиала, поэтому и здесь она отсчитывается от полуночи в loc.
+func (scrapper *Scrapper) earliestDate(now time.Time, yesterday time.Time, loc *time.Location) time.Time {
+	days := scrapper.config.MaxBackfillDays
+	if days <= 0 {
+		days = defaultBackfillDays
+	}
+	depth := scrapper.weatherAPI.MaxDepth()
+
+	earliest := yesterday
+	for i := 1; i < days; i++ {
+		prev := earliest.AddDate(0, 0, -1)
+		localStart := time.Date(prev.Year(), prev.Month(), prev.Day(), 0, 0, 0, 0, loc)
+		if depth > 0 && now.Sub(localStart) >= depth-depthMargin {
+			break
+		}
+		earliest = prev
+	}
+	return earliest
+}
+
+func truncateDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
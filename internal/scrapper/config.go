@@ -2,18 +2,172 @@ package scrapper
 
 import (
 	"github.com/spf13/viper"
+	"temperature/internal/climate"
+	"temperature/internal/degreedays"
+	"temperature/internal/heating"
 	"temperature/internal/notify"
+	"temperature/internal/storage"
+	"temperature/internal/validation"
 	"temperature/internal/weather"
+	"time"
 )
 
 var ConfigPath = "/etc/scrapper"
 
+const (
+	OpenWeatherSource = weather.OpenWeatherProvider
+	OpenMeteoSource   = weather.OpenMeteoProvider
+)
+
 type Config struct {
-	Locations []weather.Location `yaml:"locations,flow"`
-	DBPath    string             `yaml:"dbPath"`
-	Schedule  string             `yaml:"schedule"`
-	Notifier  NotifierConfig     `yaml:"notifier"`
-	Weather   WeatherConfig      `yaml:"weather"`
+	Locations       []weather.Location  `yaml:"locations,flow"`
+	DBPath          string              `yaml:"dbPath"`
+	DBDriver        string              `yaml:"dbDriver"`
+	DBDSN           string              `yaml:"dbDSN"`
+	Schedule        string              `yaml:"schedule"`
+	MaxBackfillDays int                 `yaml:"maxBackfillDays"`
+	Notifier        NotifierConfig      `yaml:"notifier"`
+	Weather         WeatherConfig       `yaml:"weather"`
+	Forecast        ForecastConfig      `yaml:"forecast"`
+	DegreeDays      DegreeDaysConfig    `yaml:"degreeDays"`
+	HeatingSeason   HeatingSeasonConfig `yaml:"heatingSeason"`
+	Climate         ClimateConfig       `yaml:"climate"`
+	Validation      validation.Rules    `yaml:"validation"`
+	Upsert          UpsertConfig        `yaml:"upsert"`
+	API             APIConfig           `yaml:"api"`
+}
+
+// OpenRepository создает репозиторий по dbDriver: для SQLite используется dbPath, для PostgreSQL - dbDSN.
+func (c *Config) OpenRepository() (storage.Repository, error) {
+	return storage.Open(c.DBDriver, c.dataSource())
+}
+
+// OpenStorage открывает БД из конфигурации, применяет миграции и политику записи.
+func (c *Config) OpenStorage() (storage.Storage, error) {
+	repo, err := c.OpenRepository()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.Init(); err != nil {
+		return nil, err
+	}
+	if err := c.Upsert.Apply(repo); err != nil {
+		return nil, err
+	}
+	return storage.NewDBStorage(repo), nil
+}
+
+// LoadConfig читает конфигурацию вспомогательных команд из каталога path.
+// Непустой dataSource заменяет путь к БД или строку подключения из конфигурации.
+func LoadConfig(path string, dataSource string) (*Config, error) {
+	ConfigPath = path
+	c := Config{}
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	if dataSource != "" {
+		c.OverrideDataSource(dataSource)
+	}
+	return &c, nil
+}
+
+// OverrideDataSource заменяет путь к БД или строку подключения, в зависимости от dbDriver.
+func (c *Config) OverrideDataSource(s string) {
+	if c.DBDriver == storage.PostgresDriver {
+		c.DBDSN = s
+	} else {
+		c.DBPath = s
+	}
+}
+
+func (c *Config) dataSource() *string {
+	if c.DBDriver == storage.PostgresDriver {
+		return &c.DBDSN
+	}
+	return &c.DBPath
+}
+
+// DefaultAPIAddr - адрес HTTP API, если он не задан в конфигурации.
+const DefaultAPIAddr = ":8080"
+
+type APIConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+func (c *APIConfig) Address() string {
+	if c.Addr == "" {
+		return DefaultAPIAddr
+	}
+	return c.Addr
+}
+
+type UpsertConfig struct {
+	Policy     string   `yaml:"policy"`
+	Priorities []string `yaml:"priorities,flow"`
+}
+
+// Apply настраивает политику обновления уже сохраненных дней в репозитории.
+func (c *UpsertConfig) Apply(repo storage.Repository) error {
+	policy, err := storage.ParseUpsertPolicy(c.Policy)
+	if err != nil {
+		return err
+	}
+	repo.SetUpsertPolicy(policy, c.Priorities)
+	return nil
+}
+
+type ClimateConfig struct {
+	ExtremeSigmas float32 `yaml:"extremeSigmas"`
+	MinYears      int     `yaml:"minYears"`
+}
+
+func (c *ClimateConfig) ExtremeThreshold() float32 {
+	if c.ExtremeSigmas <= 0 {
+		return climate.DefaultExtremeSigmas
+	}
+	return c.ExtremeSigmas
+}
+
+func (c *ClimateConfig) MinHistoryYears() int {
+	if c.MinYears <= 0 {
+		return climate.DefaultMinYears
+	}
+	return c.MinYears
+}
+
+type HeatingSeasonConfig struct {
+	Threshold *float32 `yaml:"threshold"`
+	Days      int      `yaml:"days"`
+}
+
+func (c *HeatingSeasonConfig) ThresholdTemperature() float32 {
+	if c.Threshold == nil {
+		return heating.DefaultThreshold
+	}
+	return *c.Threshold
+}
+
+func (c *HeatingSeasonConfig) ConsecutiveDays() int {
+	if c.Days <= 0 {
+		return heating.DefaultDays
+	}
+	return c.Days
+}
+
+type DegreeDaysConfig struct {
+	Base *float32 `yaml:"base"`
+}
+
+func (c *DegreeDaysConfig) BaseTemperature() float32 {
+	if c.Base == nil {
+		return degreedays.DefaultBase
+	}
+	return *c.Base
+}
+
+type ForecastConfig struct {
+	Schedule string `yaml:"schedule"`
+	Days     int    `yaml:"days"`
 }
 
 type NotifierConfig struct {
@@ -21,7 +175,21 @@ type NotifierConfig struct {
 }
 
 type WeatherConfig struct {
-	Token string `yaml:"token"`
+	Sources     []SourceConfig   `yaml:"sources,flow"`
+	Consensus   *ConsensusConfig `yaml:"consensus"`
+	Aggregation string           `yaml:"aggregation"`
+}
+
+type ConsensusConfig struct {
+	Strategy  string  `yaml:"strategy"`
+	Threshold float32 `yaml:"threshold"`
+}
+
+type SourceConfig struct {
+	Provider string        `yaml:"provider"`
+	Token    string        `yaml:"token"`
+	URL      string        `yaml:"url"`
+	Timeout  time.Duration `yaml:"timeout"`
 }
 
 func (c *Config) Init() error {
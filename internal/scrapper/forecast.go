@@ -0,0 +1,91 @@
+package scrapper
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"temperature/internal/weather"
+	"time"
+)
+
+const defaultForecastDays = 7
+
+func (scrapper *Scrapper) initForecastCron() {
+	config := scrapper.config.Forecast
+	if config.Schedule == "" {
+		logger.Info("Расписание прогноза не задано, сбор прогнозов отключен")
+		return
+	}
+	_, err := scrapper.cron.AddFunc(config.Schedule, func() {
+		logger.Info("Старт получения прогноза погоды")
+		forecasts, err := scrapper.updateForecast(time.Now())
+		if err != nil {
+			logger.Errorf("Не удалось получить прогноз: %s", err)
+			scrapper.notifier.Emit(newErrorForecastMessage())
+		} else {
+			logger.Info("Прогноз погоды получен")
+			scrapper.notifier.Emit(*newForecastMessage(forecasts))
+		}
+	})
+	if err != nil {
+		logger.Fatalf("Модуль cron завершился с ошибкой: %s", err)
+	}
+}
+
+func (scrapper *Scrapper) updateForecast(issuedAt time.Time) ([]*weather.Forecast, error) {
+	days := scrapper.config.Forecast.Days
+	if days <= 0 {
+		days = defaultForecastDays
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make([]*weather.Forecast, 0, len(scrapper.config.Locations)*days)
+	for _, location := range scrapper.config.Locations {
+		location := location
+		forecasts, err := scrapper.weatherAPI.ForecastByLocation(ctx, issuedAt, &location, days)
+		if err != nil {
+			return nil, err
+		}
+		logger.WithFields(log.Fields{
+			"филиал": location.Description,
+			"дней":   len(forecasts),
+		}).Info("Получен прогноз")
+		results = append(results, forecasts...)
+	}
+	if err := scrapper.storage.SaveForecasts(results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// newForecastMessage показывает прогноз первого провайдера каждого филиала.
+func newForecastMessage(forecasts []*weather.Forecast) *string {
+	builder := strings.Builder{}
+	builder.WriteString("Прогноз температуры: \n\r")
+	providers := make(map[string]string)
+	department := ""
+	for _, item := range forecasts {
+		name := item.Location.Description
+		if provider, ok := providers[name]; ok && provider != item.Provider {
+			continue
+		}
+		providers[name] = item.Provider
+		if name != department {
+			if department != "" {
+				builder.WriteString("\n\r")
+			}
+			builder.WriteString(fmt.Sprintf("%s: ", name))
+			department = name
+		}
+		builder.WriteString(fmt.Sprintf("%s %0.1fC; ", item.TargetDate.Format("02.01"), item.Mean))
+	}
+	message := builder.String()
+	return &message
+}
+
+func newErrorForecastMessage() string {
+	return "Получение прогноза прошло не удачно"
+}
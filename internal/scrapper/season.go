@@ -0,0 +1,37 @@
+package scrapper
+
+import (
+	"fmt"
+	"temperature/internal/heating"
+)
+
+func (scrapper *Scrapper) initSeasonDetector() {
+	config := scrapper.config.HeatingSeason
+	scrapper.season = heating.NewDetector(scrapper.storage, config.ThresholdTemperature(), config.ConsecutiveDays())
+	logger.Info("Модуль отопительного периода инициализирован")
+}
+
+// detectSeasons проверяет начало и окончание отопительного периода по каждому загруженному дню.
+func (scrapper *Scrapper) detectSeasons(results []*dayResult) {
+	for _, result := range results {
+		for _, t := range result.temperatures {
+			change, err := scrapper.season.Detect(t.Location.Description, &result.date)
+			if err != nil {
+				logger.WithField("филиал", t.Location.Description).Errorf("Не удалось определить отопительный период: %s", err)
+				continue
+			}
+			if change != nil {
+				scrapper.notifier.Emit(newSeasonMessage(change))
+			}
+		}
+	}
+}
+
+func newSeasonMessage(change *heating.Change) string {
+	if change.Active {
+		return fmt.Sprintf("%s: начало отопительного периода. %d суток подряд ниже %+0.0fC по %s",
+			change.Department, change.Days, change.Threshold, change.Date.Format("02.01.2006"))
+	}
+	return fmt.Sprintf("%s: окончание отопительного периода. %d суток подряд выше %+0.0fC по %s",
+		change.Department, change.Days, change.Threshold, change.Date.Format("02.01.2006"))
+}
@@ -0,0 +1,49 @@
+package scrapper
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"temperature/internal/notify"
+	"temperature/internal/weather"
+)
+
+func NewWeatherSource(config *WeatherConfig, notifier notify.Notifier) (weather.RecordingSource, error) {
+	items := make([]weather.ChainItem, 0, len(config.Sources))
+	// Источник хранит указатели на поля конфигурации, поэтому берется элемент среза, а не переменная цикла.
+	for i := range config.Sources {
+		sourceConfig := &config.Sources[i]
+		source, err := newWeatherSource(sourceConfig)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, weather.ChainItem{
+			Name:    sourceConfig.Provider,
+			Source:  source,
+			Timeout: sourceConfig.Timeout,
+		})
+	}
+
+	if config.Consensus == nil || len(items) < 2 {
+		return weather.NewChainSource(items), nil
+	}
+	strategy, err := weather.ParseStrategy(config.Consensus.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(log.Fields{
+		"стратегия": strategy,
+		"порог":     config.Consensus.Threshold,
+	}).Info("Источники погоды опрашиваются параллельно")
+	return weather.NewConsensusSource(items, strategy, config.Consensus.Threshold, notifier), nil
+}
+
+func newWeatherSource(config *SourceConfig) (weather.Source, error) {
+	switch config.Provider {
+	case OpenWeatherSource:
+		return weather.NewOpenWeatherAPI(&config.Token), nil
+	case OpenMeteoSource:
+		return weather.NewOpenMeteoAPI(&config.URL), nil
+	default:
+		return nil, fmt.Errorf("Неизвестный источник погоды: %s", config.Provider)
+	}
+}
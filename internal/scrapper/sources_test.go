@@ -0,0 +1,65 @@
+package scrapper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"temperature/internal/weather"
+	"testing"
+	"time"
+)
+
+// recordingTransport отвечает ошибкой на любой запрос и запоминает запрошенные адреса.
+type recordingTransport struct {
+	mutex     sync.Mutex
+	requested []string
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	t.requested = append(t.requested, r.URL.String())
+	t.mutex.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Header:     make(http.Header),
+		Request:    r,
+	}, nil
+}
+
+func TestNewWeatherSourceKeepsEachSourceConfig(t *testing.T) {
+	transport := &recordingTransport{}
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = transport
+	t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+
+	config := &WeatherConfig{Sources: []SourceConfig{
+		{Provider: OpenWeatherSource, Token: "secret"},
+		{Provider: OpenMeteoSource},
+	}}
+	source, err := NewWeatherSource(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	if _, err := source.GetWeatherByDate(context.Background(), &yesterday, &weather.Coordinates{Lat: 52, Lon: 113.5}); err == nil {
+		t.Fatal("got no error from failing sources")
+	}
+	var openWeather []string
+	for _, u := range transport.requested {
+		if strings.Contains(u, "api.openweathermap.org") {
+			openWeather = append(openWeather, u)
+		}
+	}
+	if len(openWeather) == 0 {
+		t.Fatalf("OpenWeather is not requested, requested %v", transport.requested)
+	}
+	for _, u := range openWeather {
+		if !strings.Contains(u, "appid=secret") {
+			t.Errorf("requested %s, want token secret", u)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package scrapper
+
+import (
+	log "github.com/sirupsen/logrus"
+	"temperature/internal/notify"
+	"temperature/internal/validation"
+	"temperature/internal/weather"
+	"time"
+)
+
+func (scrapper *Scrapper) initValidator() {
+	scrapper.validator = validation.New(scrapper.storage, scrapper.config.Locations, scrapper.config.Validation)
+	logger.Info("Модуль проверки значений инициализирован")
+}
+
+// Validate отправляет подозрительные значения в карантин и возвращает значения для сохранения
+// и число значений, помещенных в карантин. Ежедневное обновление и загрузка архива проверяют значения одинаково.
+// О значениях, которых еще не было в карантине, сообщает notifier.
+func Validate(validator *validation.Validator, notifier notify.Notifier, date *time.Time, temperatures []*weather.Temperature) ([]*weather.Temperature, int, error) {
+	valid, suspicious, err := validator.Validate(date, temperatures)
+	if err != nil || len(suspicious) == 0 {
+		return valid, 0, err
+	}
+	fresh, err := validator.Quarantine(suspicious)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, s := range suspicious {
+		logger.WithFields(log.Fields{
+			"дата":        date.Format("02.01.2006"),
+			"филиал":      s.Temperature.Location.Description,
+			"температура": s.Temperature.Value,
+			"причины":     s.Reasons,
+		}).Warn("Значение помещено в карантин")
+	}
+	if len(fresh) > 0 {
+		notifier.Emit(validation.NewQuarantineMessage(fresh))
+	}
+	return valid, len(suspicious), nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyEntity - ключ доступа к HTTP API. Сам ключ не хранится, только его хеш.
+type APIKeyEntity struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+	Hash string `gorm:"uniqueIndex"`
+	// Departments - доступные филиалы через запятую, пустая строка означает все филиалы.
+	Departments string
+	// RateLimit - допустимое число запросов в минуту, 0 - без ограничения.
+	RateLimit int
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// Scope возвращает доступные ключу филиалы, nil означает все филиалы.
+func (k *APIKeyEntity) Scope() []string {
+	if k.Departments == "" {
+		return nil
+	}
+	return strings.Split(k.Departments, ",")
+}
+
+func (k *APIKeyEntity) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+type APIKeyRepository interface {
+	SaveAPIKey(k *APIKeyEntity) error
+	FindAPIKey(hash string) (*APIKeyEntity, error)
+	FindAPIKeys() ([]*APIKeyEntity, error)
+	RevokeAPIKey(id uint, at time.Time) (bool, error)
+}
+
+func (repository *GormRepository) SaveAPIKey(k *APIKeyEntity) error {
+	return repository.db.Create(k).Error
+}
+
+// FindAPIKey возвращает nil, если ключа с таким хешем нет.
+func (repository *GormRepository) FindAPIKey(hash string) (*APIKeyEntity, error) {
+	var keys []*APIKeyEntity
+	err := repository.db.Where("hash = ?", hash).Limit(1).Find(&keys).Error
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+func (repository *GormRepository) FindAPIKeys() ([]*APIKeyEntity, error) {
+	var keys []*APIKeyEntity
+	err := repository.db.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey отзывает ключ. Возвращает false, если ключа нет или он уже отозван.
+func (repository *GormRepository) RevokeAPIKey(id uint, at time.Time) (bool, error) {
+	result := repository.db.Model(&APIKeyEntity{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at.UTC())
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"gorm.io/gorm/clause"
+	"temperature/internal/weather"
+	"time"
+)
+
+// ForecastEntity - снимок прогноза: значение на TargetDate, выпущенное в IssuedAt.
+type ForecastEntity struct {
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Provider    string    `gorm:"primaryKey;autoIncrement:false"`
+	IssuedAt    time.Time `gorm:"primaryKey;autoIncrement:false"`
+	TargetDate  time.Time `gorm:"primaryKey;autoIncrement:false;index"`
+	LeadDays    int
+	Temperature float32
+	Min         float32
+	Max         float32
+}
+
+type ForecastRepository interface {
+	SaveForecasts(f []*ForecastEntity) error
+	FindForecasts(department string, from *time.Time, to *time.Time) ([]*ForecastEntity, error)
+}
+
+func NewForecastEntities(forecasts []*weather.Forecast) []*ForecastEntity {
+	entities := make([]*ForecastEntity, 0, len(forecasts))
+	for _, f := range forecasts {
+		entities = append(entities, &ForecastEntity{
+			Department:  f.Location.Description,
+			Provider:    f.Provider,
+			IssuedAt:    f.IssuedAt.UTC(),
+			TargetDate:  f.TargetDate,
+			LeadDays:    f.LeadDays,
+			Temperature: f.Mean,
+			Min:         f.Min,
+			Max:         f.Max,
+		})
+	}
+	return entities
+}
+
+func (repository *GormRepository) SaveForecasts(forecasts []*ForecastEntity) error {
+	if len(forecasts) == 0 {
+		return nil
+	}
+	return repository.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(forecasts).Error
+}
+
+// FindForecasts возвращает снимки прогнозов филиала с датой прогноза в диапазоне [from, to].
+// Пустой department означает все филиалы.
+func (repository *GormRepository) FindForecasts(department string, from *time.Time, to *time.Time) ([]*ForecastEntity, error) {
+	var forecasts []*ForecastEntity
+	query := repository.db.Where("target_date BETWEEN ? AND ?", from.UTC(), to.UTC())
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Order("department, target_date, provider, lead_days").Find(&forecasts).Error
+	return forecasts, err
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "time"
+
+// Gap - непрерывный диапазон дней [From, To], за который у филиала нет суточного значения.
+type Gap struct {
+	Department string
+	From       time.Time
+	To         time.Time
+}
+
+func (g *Gap) Days() int {
+	return int(g.To.Sub(g.From).Hours()/24) + 1
+}
+
+// GetGaps возвращает пропущенные дни филиала в диапазоне [from, to].
+func (storage *DBStorage) GetGaps(department string, from *time.Time, to *time.Time) ([]*Gap, error) {
+	stored, err := storage.GetStoredDates(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := make([]*Gap, 0)
+	var current *Gap
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	for date := first; !date.After(*to); date = date.AddDate(0, 0, 1) {
+		if stored[date] {
+			current = nil
+			continue
+		}
+		if current == nil {
+			current = &Gap{Department: department, From: date}
+			gaps = append(gaps, current)
+		}
+		current.To = date
+	}
+	return gaps, nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// SchemaMigration - примененная миграция схемы БД.
+type SchemaMigration struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+type migration struct {
+	version     int
+	description string
+	up          func(tx *gorm.DB) error
+}
+
+// migrations применяются по порядку версий, каждая в своей транзакции.
+// Миграция описывает схему своими структурами, а не текущими сущностями,
+// чтобы последующие изменения сущностей не меняли уже выпущенные миграции.
+var migrations = []migration{
+	{version: 1, description: "Исходная схема с днем, месяцем и годом", up: migrateInitial},
+	{version: 2, description: "Столбец даты вместо дня, месяца и года, происхождение значений и новые таблицы", up: migrateDateColumn},
+	{version: 3, description: "Ключи доступа к HTTP API", up: migrateAPIKeys},
+}
+
+// migrate доводит схему БД до последней версии. БД, созданные до появления миграций,
+// уже содержат таблицы версии 1, поэтому ее применение к ним ничего не меняет.
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+	var applied []*SchemaMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:     m.version,
+				Description: m.description,
+				AppliedAt:   time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("Миграция %d (%s) не применена: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// temperatureV1 - схема до появления миграций, в ней хранилась только среднесуточная температура.
+type temperatureV1 struct {
+	Temperature float32
+	Department  string `gorm:"primaryKey;autoIncrement:false"`
+	Day         int    `gorm:"primaryKey;autoIncrement:false"`
+	Month       int    `gorm:"primaryKey;autoIncrement:false"`
+	Year        int    `gorm:"primaryKey;autoIncrement:false"`
+}
+
+func (temperatureV1) TableName() string { return "temperature_entities" }
+
+func migrateInitial(tx *gorm.DB) error {
+	return tx.AutoMigrate(&temperatureV1{})
+}
+
+type temperatureV2 struct {
+	Temperature float32
+	Min         float32
+	Max         float32
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Date        time.Time `gorm:"type:date;primaryKey;autoIncrement:false;index"`
+	Estimated   bool
+	Source      string `gorm:"index"`
+	IngestedAt  time.Time
+	Aggregation string
+	Samples     int
+}
+
+type observationV2 struct {
+	Department    string    `gorm:"primaryKey;autoIncrement:false"`
+	Lat           float32   `gorm:"primaryKey;autoIncrement:false"`
+	Lon           float32   `gorm:"primaryKey;autoIncrement:false"`
+	Time          time.Time `gorm:"primaryKey;autoIncrement:false"`
+	Temperature   float32
+	WindSpeed     *float32
+	WindGust      *float32
+	Precipitation *float32
+	Humidity      *float32
+	SnowDepth     *float32
+}
+
+type forecastV2 struct {
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Provider    string    `gorm:"primaryKey;autoIncrement:false"`
+	IssuedAt    time.Time `gorm:"primaryKey;autoIncrement:false"`
+	TargetDate  time.Time `gorm:"primaryKey;autoIncrement:false;index"`
+	LeadDays    int
+	Temperature float32
+	Min         float32
+	Max         float32
+}
+
+type heatingSeasonV2 struct {
+	Department string `gorm:"primaryKey;autoIncrement:false"`
+	Active     bool
+	Since      time.Time
+}
+
+type quarantineV2 struct {
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Date        time.Time `gorm:"type:date;primaryKey;autoIncrement:false"`
+	Temperature float32
+	Min         float32
+	Max         float32
+	Reasons     string
+	CreatedAt   time.Time
+}
+
+type revisionV2 struct {
+	ID          uint      `gorm:"primaryKey"`
+	Department  string    `gorm:"index:idx_revision_date"`
+	Date        time.Time `gorm:"type:date;index:idx_revision_date"`
+	Temperature float32
+	Min         float32
+	Max         float32
+	Estimated   bool
+	Source      string
+	IngestedAt  time.Time
+	Aggregation string
+	Samples     int
+	RevisedAt   time.Time
+	ReplacedBy  string
+}
+
+func (temperatureV2) TableName() string   { return "temperature_entities" }
+func (observationV2) TableName() string   { return "observation_entities" }
+func (forecastV2) TableName() string      { return "forecast_entities" }
+func (heatingSeasonV2) TableName() string { return "heating_season_entities" }
+func (quarantineV2) TableName() string    { return "quarantine_entities" }
+func (revisionV2) TableName() string      { return "revision_entities" }
+
+// migrateDateColumn пересоздает таблицу температур со столбцом типа date вместо дня, месяца и года
+// и создает таблицы, которых не было в исходной схеме.
+// SQLite не умеет менять первичный ключ, поэтому строки читаются, таблица создается заново и строки переносятся.
+func migrateDateColumn(tx *gorm.DB) error {
+	var temperatures []*temperatureV1
+	if err := tx.Find(&temperatures).Error; err != nil {
+		return err
+	}
+	converted := make([]*temperatureV2, 0, len(temperatures))
+	for _, r := range temperatures {
+		// В исходной схеме не было минимума и максимума, поэтому они берутся равными среднесуточной,
+		// иначе агрегаты за период показывали бы 0.
+		converted = append(converted, &temperatureV2{
+			Temperature: r.Temperature,
+			Min:         r.Temperature,
+			Max:         r.Temperature,
+			Department:  r.Department,
+			Date:        time.Date(r.Year, time.Month(r.Month), r.Day, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	if err := tx.Migrator().DropTable(&temperatureV1{}); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateTable(&temperatureV2{}); err != nil {
+		return err
+	}
+	if len(converted) > 0 {
+		if err := tx.CreateInBatches(converted, 500).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Migrator().CreateTable(
+		&observationV2{},
+		&forecastV2{},
+		&heatingSeasonV2{},
+		&quarantineV2{},
+		&revisionV2{},
+	)
+}
+
+type apiKeyV3 struct {
+	ID          uint `gorm:"primaryKey"`
+	Name        string
+	Hash        string `gorm:"uniqueIndex"`
+	Departments string
+	RateLimit   int
+	CreatedAt   time.Time
+	RevokedAt   *time.Time
+}
+
+func (apiKeyV3) TableName() string { return "api_key_entities" }
+
+func migrateAPIKeys(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&apiKeyV3{})
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+)
+
+// Normal - климатическая норма филиала на календарный день по многолетним суточным значениям.
+type Normal struct {
+	Department string
+	Month      int
+	Day        int
+	Mean       float32
+	Std        float32
+	Years      int
+}
+
+type NormalRepository interface {
+	FindNormals(department string, excludeYear int) ([]*Normal, error)
+}
+
+// FindNormals считает среднее и выборочное стандартное отклонение для каждого календарного дня.
+// Год excludeYear в расчет не входит, чтобы оцениваемый день не влиял на собственную норму,
+// восстановленные значения не учитываются.
+// Пустой department означает все филиалы.
+func (repository *GormRepository) FindNormals(department string, excludeYear int) ([]*Normal, error) {
+	var rows []struct {
+		Department string
+		Month      int
+		Day        int
+		Mean       float64
+		Square     float64
+		Years      int
+	}
+	query := repository.db.Model(&TemperatureEntity{}).
+		Select(fmt.Sprintf("department, %s AS month, %s AS day, ", repository.datePart("month"), repository.datePart("day"))+
+			"AVG(temperature) AS mean, AVG(temperature * temperature) AS square, COUNT(*) AS years").
+		Where(repository.datePart("year")+" <> ? AND estimated = ?", excludeYear, false)
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Group("department, month, day").Order("department, month, day").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	normals := make([]*Normal, 0, len(rows))
+	for _, row := range rows {
+		var std float64
+		if row.Years > 1 {
+			n := float64(row.Years)
+			std = math.Sqrt(math.Max(row.Square-row.Mean*row.Mean, 0) * n / (n - 1))
+		}
+		normals = append(normals, &Normal{
+			Department: row.Department,
+			Month:      row.Month,
+			Day:        row.Day,
+			Mean:       float32(row.Mean),
+			Std:        float32(std),
+			Years:      row.Years,
+		})
+	}
+	return normals, nil
+}
+
+var sqliteDateParts = map[string]string{
+	"year":  "%Y",
+	"month": "%m",
+	"day":   "%d",
+}
+
+// datePart возвращает SQL-выражение, извлекающее год, месяц или день из столбца date в диалекте БД.
+func (repository *GormRepository) datePart(part string) string {
+	if repository.db.Dialector.Name() == PostgresDriver {
+		return fmt.Sprintf("CAST(EXTRACT(%s FROM date) AS INTEGER)", part)
+	}
+	return fmt.Sprintf("CAST(strftime('%s', date) AS INTEGER)", sqliteDateParts[part])
+}
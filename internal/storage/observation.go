@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"temperature/internal/weather"
+	"time"
+)
+
+type ObservationEntity struct {
+	Department    string    `gorm:"primaryKey;autoIncrement:false"`
+	Lat           float32   `gorm:"primaryKey;autoIncrement:false"`
+	Lon           float32   `gorm:"primaryKey;autoIncrement:false"`
+	Time          time.Time `gorm:"primaryKey;autoIncrement:false"`
+	Temperature   float32
+	WindSpeed     *float32
+	WindGust      *float32
+	Precipitation *float32
+	Humidity      *float32
+	SnowDepth     *float32
+}
+
+type ObservationRepository interface {
+	FindObservations(department string, from *time.Time, to *time.Time) ([]*ObservationEntity, error)
+}
+
+func NewObservationEntities(department string, observations []weather.Observation) []*ObservationEntity {
+	entities := make([]*ObservationEntity, 0, len(observations))
+	for _, o := range observations {
+		entities = append(entities, &ObservationEntity{
+			Department:    department,
+			Lat:           o.Coordinates.Lat,
+			Lon:           o.Coordinates.Lon,
+			Time:          o.Time.UTC(),
+			Temperature:   o.Temperature,
+			WindSpeed:     o.WindSpeed,
+			WindGust:      o.WindGust,
+			Precipitation: o.Precipitation,
+			Humidity:      o.Humidity,
+			SnowDepth:     o.SnowDepth,
+		})
+	}
+	return entities
+}
+
+func (o *ObservationEntity) Observation() weather.Observation {
+	return weather.Observation{
+		Coordinates: weather.Coordinates{Lat: o.Lat, Lon: o.Lon},
+		Time:        o.Time,
+		Temperature: o.Temperature,
+		Variables: weather.Variables{
+			WindSpeed:     o.WindSpeed,
+			WindGust:      o.WindGust,
+			Precipitation: o.Precipitation,
+			Humidity:      o.Humidity,
+			SnowDepth:     o.SnowDepth,
+		},
+	}
+}
+
+// saveObservations заменяет наблюдения дня. Вызывается, только если суточное значение принято по политике обновления.
+func saveObservations(tx *gorm.DB, observations []*ObservationEntity) error {
+	if len(observations) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(observations).Error
+}
+
+func (repository *GormRepository) FindObservations(department string, from *time.Time, to *time.Time) ([]*ObservationEntity, error) {
+	var observations []*ObservationEntity
+	err := repository.db.
+		Where("department = ? AND time >= ? AND time < ?", department, from.UTC(), to.UTC()).
+		Order("time, lat, lon").
+		Find(&observations).Error
+	return observations, err
+}
@@ -0,0 +1,63 @@
+package storage
+
+import "time"
+
+// ProvenanceFilter отбирает суточные значения по происхождению. Пустые поля не участвуют в отборе.
+type ProvenanceFilter struct {
+	Department string
+	// Source сравнивается по вхождению: "openmeteo" найдет и значения, полученные от нескольких провайдеров.
+	Source       string
+	Aggregation  string
+	Estimated    *bool
+	From         *time.Time
+	To           *time.Time
+	IngestedFrom *time.Time
+	IngestedTo   *time.Time
+}
+
+type ProvenanceRepository interface {
+	FindByProvenance(filter *ProvenanceFilter) ([]*TemperatureEntity, error)
+	FindSources(department string) ([]string, error)
+}
+
+func (repository *GormRepository) FindByProvenance(filter *ProvenanceFilter) ([]*TemperatureEntity, error) {
+	var temps []*TemperatureEntity
+	query := repository.db.Model(&TemperatureEntity{})
+	if filter.Department != "" {
+		query = query.Where("department = ?", filter.Department)
+	}
+	if filter.Source != "" {
+		query = query.Where("source LIKE ?", "%"+filter.Source+"%")
+	}
+	if filter.Aggregation != "" {
+		query = query.Where("aggregation = ?", filter.Aggregation)
+	}
+	if filter.Estimated != nil {
+		query = query.Where("estimated = ?", *filter.Estimated)
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", dateOf(filter.From))
+	}
+	if filter.To != nil {
+		query = query.Where("date <= ?", dateOf(filter.To))
+	}
+	if filter.IngestedFrom != nil {
+		query = query.Where("ingested_at >= ?", filter.IngestedFrom.UTC())
+	}
+	if filter.IngestedTo != nil {
+		query = query.Where("ingested_at <= ?", filter.IngestedTo.UTC())
+	}
+	err := query.Order("date, department").Find(&temps).Error
+	return temps, err
+}
+
+// FindSources возвращает все источники, из которых получены значения филиала. Пустой department означает все филиалы.
+func (repository *GormRepository) FindSources(department string) ([]string, error) {
+	var sources []string
+	query := repository.db.Model(&TemperatureEntity{}).Distinct("source")
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Order("source").Pluck("source", &sources).Error
+	return sources, err
+}
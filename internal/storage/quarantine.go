@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// QuarantineEntity - подозрительное суточное значение, не прошедшее проверку и не сохраненное в основную таблицу.
+type QuarantineEntity struct {
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Date        time.Time `gorm:"type:date;primaryKey;autoIncrement:false"`
+	Temperature float32
+	Min         float32
+	Max         float32
+	Reasons     string
+	CreatedAt   time.Time
+}
+
+type QuarantineRepository interface {
+	SaveQuarantine(q []*QuarantineEntity) error
+	FindQuarantine(department string, from *time.Time, to *time.Time) ([]*QuarantineEntity, error)
+}
+
+func (repository *GormRepository) SaveQuarantine(quarantine []*QuarantineEntity) error {
+	if len(quarantine) == 0 {
+		return nil
+	}
+	for _, q := range quarantine {
+		q.Date = dateOf(&q.Date)
+	}
+	return repository.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(quarantine).Error
+}
+
+// FindQuarantine возвращает значения в карантине за [from, to]. Пустой department означает все филиалы.
+func (repository *GormRepository) FindQuarantine(department string, from *time.Time, to *time.Time) ([]*QuarantineEntity, error) {
+	var quarantine []*QuarantineEntity
+	query := repository.db.Where("date BETWEEN ? AND ?", dateOf(from), dateOf(to))
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Order("date, department").Find(&quarantine).Error
+	return quarantine, err
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Period string
+
+const (
+	MonthPeriod   Period = "month"
+	QuarterPeriod Period = "quarter"
+	YearPeriod    Period = "year"
+)
+
+func ParsePeriod(s string) (Period, error) {
+	switch period := Period(s); period {
+	case MonthPeriod, QuarterPeriod, YearPeriod:
+		return period, nil
+	default:
+		return "", fmt.Errorf("Неизвестный период агрегации: %s", s)
+	}
+}
+
+// TemperatureQuery отбирает суточные значения. Пустые поля не участвуют в отборе.
+// Limit и Offset задают страницу, нулевой Limit означает все строки.
+type TemperatureQuery struct {
+	Departments []string
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+	Offset      int
+}
+
+// Aggregate - суточные значения филиала, сведенные за месяц, квартал или год.
+// Number - номер месяца или квартала в году, для года 0.
+type Aggregate struct {
+	Department string
+	Period     Period
+	Year       int
+	Number     int
+	From       time.Time
+	To         time.Time
+	Mean       float32
+	Min        float32
+	Max        float32
+	Days       int
+	Estimated  int
+}
+
+type QueryRepository interface {
+	QueryTemperatures(q *TemperatureQuery) ([]*TemperatureEntity, error)
+	CountTemperatures(q *TemperatureQuery) (int64, error)
+	AggregateTemperatures(q *TemperatureQuery, period Period) ([]*Aggregate, error)
+	IterateTemperatures(q *TemperatureQuery) (*TemperatureIterator, error)
+}
+
+func (repository *GormRepository) QueryTemperatures(q *TemperatureQuery) ([]*TemperatureEntity, error) {
+	var temps []*TemperatureEntity
+	err := paginate(repository.filter(q).Order("date, department"), q).Find(&temps).Error
+	return temps, err
+}
+
+// CountTemperatures возвращает число строк, подходящих под q без учета страницы.
+func (repository *GormRepository) CountTemperatures(q *TemperatureQuery) (int64, error) {
+	var count int64
+	err := repository.filter(q).Count(&count).Error
+	return count, err
+}
+
+// AggregateTemperatures сводит суточные значения за период средствами БД.
+// Mean - среднее среднесуточных, Min и Max - экстремумы суточных минимумов и максимумов,
+// Estimated - сколько дней периода восстановлено. Страница q применяется к периодам.
+func (repository *GormRepository) AggregateTemperatures(q *TemperatureQuery, period Period) ([]*Aggregate, error) {
+	var number string
+	switch period {
+	case MonthPeriod:
+		number = repository.datePart("month")
+	case QuarterPeriod:
+		number = fmt.Sprintf("((%s - 1) / 3 + 1)", repository.datePart("month"))
+	case YearPeriod:
+		number = "0"
+	default:
+		return nil, fmt.Errorf("Неизвестный период агрегации: %s", period)
+	}
+
+	var rows []*struct {
+		Department string
+		Year       int
+		Number     int
+		Mean       float64
+		Min        float64
+		Max        float64
+		Days       int
+		Estimated  int
+	}
+	query := repository.filter(q).
+		Select(fmt.Sprintf("department, %s AS year, %s AS number, ", repository.datePart("year"), number) +
+			"AVG(temperature) AS mean, MIN(min) AS min, MAX(max) AS max, COUNT(*) AS days, " +
+			"SUM(CASE WHEN estimated THEN 1 ELSE 0 END) AS estimated").
+		Group("department, year, number").
+		Order("department, year, number")
+	if err := paginate(query, q).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	aggregates := make([]*Aggregate, 0, len(rows))
+	for _, row := range rows {
+		from, to := periodBounds(period, row.Year, row.Number)
+		aggregates = append(aggregates, &Aggregate{
+			Department: row.Department,
+			Period:     period,
+			Year:       row.Year,
+			Number:     row.Number,
+			From:       from,
+			To:         to,
+			Mean:       float32(row.Mean),
+			Min:        float32(row.Min),
+			Max:        float32(row.Max),
+			Days:       row.Days,
+			Estimated:  row.Estimated,
+		})
+	}
+	return aggregates, nil
+}
+
+// TemperatureIterator читает результат запроса построчно, не загружая его в память целиком.
+// После использования итератор нужно закрыть.
+type TemperatureIterator struct {
+	db      *gorm.DB
+	rows    *sql.Rows
+	current *TemperatureEntity
+	err     error
+}
+
+func (repository *GormRepository) IterateTemperatures(q *TemperatureQuery) (*TemperatureIterator, error) {
+	query := paginate(repository.filter(q).Order("date, department"), q)
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, err
+	}
+	return &TemperatureIterator{db: query, rows: rows}, nil
+}
+
+func (it *TemperatureIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	it.current = &TemperatureEntity{}
+	if it.err = it.db.ScanRows(it.rows, it.current); it.err != nil {
+		return false
+	}
+	return true
+}
+
+func (it *TemperatureIterator) Temperature() *TemperatureEntity {
+	return it.current
+}
+
+// Err возвращает ошибку, на которой остановилось чтение.
+func (it *TemperatureIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *TemperatureIterator) Close() error {
+	return it.rows.Close()
+}
+
+func (repository *GormRepository) filter(q *TemperatureQuery) *gorm.DB {
+	query := repository.db.Model(&TemperatureEntity{})
+	if len(q.Departments) > 0 {
+		query = query.Where("department IN ?", q.Departments)
+	}
+	if q.From != nil {
+		query = query.Where("date >= ?", dateOf(q.From))
+	}
+	if q.To != nil {
+		query = query.Where("date <= ?", dateOf(q.To))
+	}
+	return query
+}
+
+func paginate(query *gorm.DB, q *TemperatureQuery) *gorm.DB {
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		query = query.Offset(q.Offset)
+	}
+	return query
+}
+
+// periodBounds возвращает первый и последний день периода.
+func periodBounds(period Period, year int, number int) (time.Time, time.Time) {
+	switch period {
+	case MonthPeriod:
+		from := time.Date(year, time.Month(number), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, -1)
+	case QuarterPeriod:
+		from := time.Date(year, time.Month((number-1)*3+1), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 3, -1)
+	default:
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, -1)
+	}
+}
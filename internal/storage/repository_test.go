@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"fmt"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postgresDSNEnv - DSN тестовой БД PostgreSQL. Без него тесты на PostgreSQL пропускаются, SQLite проверяется всегда.
+const postgresDSNEnv = "TEMPERATURE_TEST_PG_DSN"
+
+// sessionTimeZone смещен к западу от UTC, чтобы ошибка в часовом поясе сессии сдвигала даты на предыдущий день.
+const sessionTimeZone = "America/Los_Angeles"
+
+// backend - БД, на которой выполняются тесты хранилища.
+type backend struct {
+	name string
+	// dsn возвращает источник данных пустой БД.
+	dsn       func(t *testing.T) string
+	dialector func(dsn string) gorm.Dialector
+	open      func(dsn *string) Repository
+}
+
+var backends = []backend{
+	{name: SQLiteDriver, dsn: sqliteDSN, dialector: sqlite.Open, open: New},
+	{name: PostgresDriver, dsn: postgresDSN, dialector: postgres.Open, open: NewPostgres},
+}
+
+// forEachBackend выполняет test отдельным подтестом для каждой БД.
+func forEachBackend(t *testing.T, test func(t *testing.T, b backend)) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			test(t, b)
+		})
+	}
+}
+
+func sqliteDSN(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "temperature.db")
+}
+
+// postgresDSN создает отдельную схему для теста и возвращает DSN, в котором она стоит в search_path.
+// Схема удаляется после теста.
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s не задан", postgresDSNEnv)
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("temperature_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Error(err)
+		}
+		if db, err := admin.DB(); err == nil {
+			db.Close()
+		}
+	})
+
+	params := map[string]string{"search_path": schema, "timezone": sessionTimeZone}
+	for key, value := range params {
+		if strings.Contains(dsn, "://") {
+			separator := "&"
+			if !strings.Contains(dsn, "?") {
+				separator = "?"
+			}
+			dsn += separator + key + "=" + value
+		} else {
+			dsn += " " + key + "=" + value
+		}
+	}
+	return dsn
+}
+
+func openRepository(t *testing.T, b backend) *GormRepository {
+	t.Helper()
+	dsn := b.dsn(t)
+	repository := b.open(&dsn).(*GormRepository)
+	if err := repository.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMigratesEmptyDatabase(t *testing.T) {
+	forEachBackend(t, testMigratesEmptyDatabase)
+}
+
+func testMigratesEmptyDatabase(t *testing.T, b backend) {
+	repository := openRepository(t, b)
+
+	var applied []*SchemaMigration
+	if err := repository.db.Order("version").Find(&applied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	if repository.db.Migrator().HasTable("degree_day_entities") {
+		t.Error("degree_day_entities is created")
+	}
+	for _, model := range []interface{}{&TemperatureEntity{}, &QuarantineEntity{}, &RevisionEntity{}} {
+		columns, err := repository.db.Migrator().ColumnTypes(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range columns {
+			if column.Name() == "date" && !strings.EqualFold(column.DatabaseTypeName(), "date") {
+				t.Errorf("%T date is %s, want date", model, column.DatabaseTypeName())
+			}
+		}
+	}
+
+	// Повторная инициализация не применяет миграции заново.
+	if err := repository.Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratesBaselineSchema(t *testing.T) {
+	forEachBackend(t, testMigratesBaselineSchema)
+}
+
+func testMigratesBaselineSchema(t *testing.T, b backend) {
+	dsn := b.dsn(t)
+	baseline, err := gorm.Open(b.dialector(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateInitial(baseline); err != nil {
+		t.Fatal(err)
+	}
+	rows := []*temperatureV1{
+		{Department: "Чита", Day: 5, Month: 1, Year: 2022, Temperature: -25},
+		{Department: "Чита", Day: 6, Month: 1, Year: 2022, Temperature: -27},
+	}
+	if err := baseline.Create(rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if db, err := baseline.DB(); err == nil {
+		db.Close()
+	}
+
+	repository := b.open(&dsn).(*GormRepository)
+	if err := repository.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := day(2022, time.January, 1), day(2022, time.January, 31)
+	temperatures, err := repository.FindTemperatures("Чита", &from, &to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(temperatures) != 2 || !temperatures[0].Date.Equal(day(2022, time.January, 5)) || temperatures[0].Temperature != -25 {
+		t.Fatalf("got %+v, want 05.01.2022 and 06.01.2022", temperatures)
+	}
+	months, err := repository.AggregateTemperatures(&TemperatureQuery{}, MonthPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || months[0].Min != -27 || months[0].Max != -25 {
+		t.Errorf("got %+v, want baseline means as the January extremes", months)
+	}
+
+	// Таблицы, которых не было в исходной схеме, созданы.
+	if err := repository.Save(&TemperatureEntity{Department: "Чита", Date: day(2022, time.January, 5), Temperature: -26, Source: "rp5"}); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := repository.FindRevisions("Чита", &from, &to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Temperature != -25 {
+		t.Fatalf("got %+v, want the baseline value as a revision", revisions)
+	}
+}
+
+func TestUpsertStoresCalendarDay(t *testing.T) {
+	forEachBackend(t, testUpsertStoresCalendarDay)
+}
+
+func testUpsertStoresCalendarDay(t *testing.T, b backend) {
+	repository := openRepository(t, b)
+	repository.SetUpsertPolicy(PriorityPolicy, []string{"openmeteo", "openweather"})
+
+	chita := time.FixedZone("Asia/Chita", 9*60*60)
+	local := time.Date(2022, time.January, 5, 0, 0, 0, 0, chita)
+	if err := repository.Save(&TemperatureEntity{Department: "Чита", Date: local, Temperature: -25, Source: "openweather"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Save(&TemperatureEntity{Department: "Чита", Date: day(2022, time.January, 5), Temperature: -27, Source: "openmeteo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Save(&TemperatureEntity{Department: "Чита", Date: local, Temperature: -20, Source: "openweather"}); err != nil {
+		t.Fatal(err)
+	}
+
+	date := day(2022, time.January, 5)
+	temperatures, err := repository.FindTemperatures("Чита", &date, &date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(temperatures) != 1 {
+		t.Fatalf("got %d days, want 1", len(temperatures))
+	}
+	if got := temperatures[0]; !got.Date.Equal(date) || got.Temperature != -27 || got.Source != "openmeteo" {
+		t.Errorf("got %s %v from %s, want 05.01.2022 -27 from openmeteo", got.Date, got.Temperature, got.Source)
+	}
+	revisions, err := repository.FindRevisions("Чита", &date, &date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Temperature != -25 || revisions[0].ReplacedBy != "openmeteo" {
+		t.Errorf("got %+v, want -25 replaced by openmeteo", revisions)
+	}
+}
+
+func TestQueryAndAggregate(t *testing.T) {
+	forEachBackend(t, testQueryAndAggregate)
+}
+
+func testQueryAndAggregate(t *testing.T, b backend) {
+	repository := openRepository(t, b)
+	temperatures := []*TemperatureEntity{
+		{Department: "Чита", Date: day(2022, time.January, 31), Temperature: -30, Min: -35, Max: -25},
+		{Department: "Чита", Date: day(2022, time.February, 1), Temperature: -20, Min: -28, Max: -15, Estimated: true},
+		{Department: "Чита", Date: day(2022, time.February, 2), Temperature: -10, Min: -12, Max: -5},
+		{Department: "Улан-Удэ", Date: day(2022, time.February, 1), Temperature: -18, Min: -22, Max: -14},
+	}
+	if err := repository.SaveAll(temperatures); err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := day(2022, time.February, 1), day(2022, time.February, 28)
+	found, err := repository.QueryTemperatures(&TemperatureQuery{Departments: []string{"Чита"}, From: &from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || !found[0].Date.Equal(from) {
+		t.Fatalf("got %+v, want 01.02.2022 and 02.02.2022", found)
+	}
+	count, err := repository.CountTemperatures(&TemperatureQuery{From: &from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("counted %d, want 3", count)
+	}
+
+	months, err := repository.AggregateTemperatures(&TemperatureQuery{Departments: []string{"Чита"}}, MonthPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 2 {
+		t.Fatalf("got %d months, want 2", len(months))
+	}
+	if january := months[0]; january.Year != 2022 || january.Number != 1 || january.Days != 1 || january.Mean != -30 {
+		t.Errorf("got %+v, want January of one day", january)
+	}
+	if february := months[1]; february.Number != 2 || february.Days != 2 || february.Mean != -15 ||
+		february.Min != -28 || february.Max != -5 || february.Estimated != 1 {
+		t.Errorf("got %+v, want February of two days, one estimated", february)
+	}
+
+	quarters, err := repository.AggregateTemperatures(&TemperatureQuery{}, QuarterPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarters) != 2 || quarters[0].Department != "Улан-Удэ" || quarters[1].Number != 1 || quarters[1].Days != 3 {
+		t.Errorf("got %+v, want the first quarter of each department", quarters)
+	}
+}
+
+func TestNormals(t *testing.T) {
+	forEachBackend(t, testNormals)
+}
+
+func testNormals(t *testing.T, b backend) {
+	repository := openRepository(t, b)
+	temperatures := []*TemperatureEntity{
+		{Department: "Чита", Date: day(2020, time.March, 1), Temperature: -12},
+		{Department: "Чита", Date: day(2021, time.March, 1), Temperature: -8},
+		{Department: "Чита", Date: day(2022, time.March, 1), Temperature: 5},
+		{Department: "Чита", Date: day(2019, time.March, 1), Temperature: 30, Estimated: true},
+	}
+	if err := repository.SaveAll(temperatures); err != nil {
+		t.Fatal(err)
+	}
+
+	normals, err := repository.FindNormals("Чита", 2022)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(normals) != 1 {
+		t.Fatalf("got %d normals, want 1", len(normals))
+	}
+	if n := normals[0]; n.Month != 3 || n.Day != 1 || n.Years != 2 || n.Mean != -10 {
+		t.Errorf("got %+v, want 01.03 of 2 years with mean -10", n)
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// UpsertPolicy определяет, что делать, если значение за день филиала уже сохранено.
+type UpsertPolicy string
+
+const (
+	KeepFirstPolicy UpsertPolicy = "keep-first"
+	OverwritePolicy UpsertPolicy = "overwrite"
+	// PriorityPolicy заменяет значение, только если новый источник не ниже по приоритету.
+	PriorityPolicy UpsertPolicy = "priority"
+)
+
+func ParseUpsertPolicy(s string) (UpsertPolicy, error) {
+	switch policy := UpsertPolicy(s); policy {
+	case KeepFirstPolicy, OverwritePolicy, PriorityPolicy:
+		return policy, nil
+	case "":
+		return OverwritePolicy, nil
+	default:
+		return "", fmt.Errorf("Неизвестная политика сохранения: %s", s)
+	}
+}
+
+// RevisionEntity - прежнее суточное значение, замененное новым. Позволяет проследить все исправления.
+type RevisionEntity struct {
+	ID          uint      `gorm:"primaryKey"`
+	Department  string    `gorm:"index:idx_revision_date"`
+	Date        time.Time `gorm:"type:date;index:idx_revision_date"`
+	Temperature float32
+	Min         float32
+	Max         float32
+	Estimated   bool
+	Source      string
+	IngestedAt  time.Time
+	Aggregation string
+	Samples     int
+	RevisedAt   time.Time
+	ReplacedBy  string
+}
+
+type RevisionRepository interface {
+	FindRevisions(department string, from *time.Time, to *time.Time) ([]*RevisionEntity, error)
+}
+
+func newRevision(old *TemperatureEntity, replacedBy *TemperatureEntity) *RevisionEntity {
+	return &RevisionEntity{
+		Department:  old.Department,
+		Date:        old.Date,
+		Temperature: old.Temperature,
+		Min:         old.Min,
+		Max:         old.Max,
+		Estimated:   old.Estimated,
+		Source:      old.Source,
+		IngestedAt:  old.IngestedAt,
+		Aggregation: old.Aggregation,
+		Samples:     old.Samples,
+		RevisedAt:   time.Now().UTC(),
+		ReplacedBy:  replacedBy.Source,
+	}
+}
+
+// replaces решает, заменяет ли новое значение сохраненное.
+// Полученное из источника значение всегда заменяет восстановленное и никогда не заменяется им.
+func (repository *GormRepository) replaces(old *TemperatureEntity, t *TemperatureEntity) bool {
+	if old.Estimated != t.Estimated {
+		return old.Estimated
+	}
+	switch repository.policy {
+	case KeepFirstPolicy:
+		return false
+	case PriorityPolicy:
+		return repository.rank(t.Source) <= repository.rank(old.Source)
+	default:
+		return true
+	}
+}
+
+// rank возвращает позицию источника в списке приоритетов, источники вне списка имеют наименьший приоритет.
+// Значение, полученное от нескольких провайдеров через запятую, получает приоритет наименее надежного из них.
+func (repository *GormRepository) rank(source string) int {
+	rank := 0
+	for _, provider := range strings.Split(source, ",") {
+		if r := repository.providerRank(strings.TrimSpace(provider)); r > rank {
+			rank = r
+		}
+	}
+	return rank
+}
+
+func (repository *GormRepository) providerRank(provider string) int {
+	for i, name := range repository.priorities {
+		if provider == name {
+			return i
+		}
+	}
+	return len(repository.priorities)
+}
+
+func (repository *GormRepository) upsert(tx *gorm.DB, t *TemperatureEntity) error {
+	t.Date = dateOf(&t.Date)
+	var existing []*TemperatureEntity
+	err := tx.Where("department = ? AND date = ?", t.Department, t.Date).
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return saveObservations(tx, t.Observations)
+	}
+
+	old := existing[0]
+	if !repository.replaces(old, t) {
+		return nil
+	}
+	if old.Temperature != t.Temperature || old.Min != t.Min || old.Max != t.Max || old.Estimated != t.Estimated {
+		if err := tx.Create(newRevision(old, t)).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Save(t).Error; err != nil {
+		return err
+	}
+	return saveObservations(tx, t.Observations)
+}
+
+func (repository *GormRepository) FindRevisions(department string, from *time.Time, to *time.Time) ([]*RevisionEntity, error) {
+	var revisions []*RevisionEntity
+	query := repository.db.Where("date BETWEEN ? AND ?", dateOf(from), dateOf(to))
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Order("date, department, revised_at").Find(&revisions).Error
+	return revisions, err
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"temperature/internal/weather"
+	"testing"
+	"time"
+)
+
+func TestUpsertPolicies(t *testing.T) {
+	forEachBackend(t, testUpsertPolicies)
+}
+
+func testUpsertPolicies(t *testing.T, b backend) {
+	date := day(2022, time.January, 5)
+	observed := func(temperature float32, source string) *TemperatureEntity {
+		return &TemperatureEntity{Department: "Чита", Date: date, Temperature: temperature, Min: temperature, Max: temperature, Source: source}
+	}
+	estimated := func(temperature float32) *TemperatureEntity {
+		t := observed(temperature, "linear")
+		t.Estimated = true
+		return t
+	}
+	priorities := []string{"openmeteo", "openweather"}
+
+	tests := []struct {
+		name       string
+		policy     UpsertPolicy
+		old        *TemperatureEntity
+		new        *TemperatureEntity
+		want       float32
+		wantSource string
+		revisions  int
+	}{
+		{"keep-first keeps the stored value", KeepFirstPolicy, observed(-25, "openmeteo"), observed(-20, "openweather"), -25, "openmeteo", 0},
+		{"overwrite replaces the stored value", OverwritePolicy, observed(-25, "openmeteo"), observed(-20, "openweather"), -20, "openweather", 1},
+		{"overwrite with the same values writes no revision", OverwritePolicy, observed(-25, "openmeteo"), observed(-25, "openweather"), -25, "openweather", 0},
+		{"priority replaces by a more trusted source", PriorityPolicy, observed(-25, "openweather"), observed(-20, "openmeteo"), -20, "openmeteo", 1},
+		{"priority replaces by the same source", PriorityPolicy, observed(-25, "openmeteo"), observed(-20, "openmeteo"), -20, "openmeteo", 1},
+		{"priority keeps against a less trusted source", PriorityPolicy, observed(-25, "openmeteo"), observed(-20, "openweather"), -25, "openmeteo", 0},
+		{"priority keeps against an unknown source", PriorityPolicy, observed(-25, "openweather"), observed(-20, "archive.xlsx"), -25, "openweather", 0},
+		{"priority replaces an unknown source by a known one", PriorityPolicy, observed(-25, "archive.xlsx"), observed(-20, "openweather"), -20, "openweather", 1},
+		{"priority ranks several providers by the least trusted", PriorityPolicy, observed(-25, "openmeteo"), observed(-20, "openmeteo,openweather"), -25, "openmeteo", 0},
+		{"priority does not match a provider by substring", PriorityPolicy, observed(-25, "openweather"), observed(-20, "openmeteo2"), -25, "openweather", 0},
+		{"observed replaces estimated under keep-first", KeepFirstPolicy, estimated(-25), observed(-20, "openweather"), -20, "openweather", 1},
+		{"estimated never replaces observed", OverwritePolicy, observed(-25, "openmeteo"), estimated(-20), -25, "openmeteo", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := openRepository(t, b)
+			repository.SetUpsertPolicy(tt.policy, priorities)
+			if err := repository.Save(tt.old); err != nil {
+				t.Fatal(err)
+			}
+			if err := repository.Save(tt.new); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := repository.FindTemperatures("Чита", &date, &date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 || stored[0].Temperature != tt.want || stored[0].Source != tt.wantSource {
+				t.Fatalf("got %+v, want %v from %s", stored, tt.want, tt.wantSource)
+			}
+			revisions, err := repository.FindRevisions("Чита", &date, &date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != tt.revisions {
+				t.Fatalf("got %d revisions, want %d", len(revisions), tt.revisions)
+			}
+			if tt.revisions > 0 && (revisions[0].Temperature != tt.old.Temperature || revisions[0].ReplacedBy != tt.new.Source) {
+				t.Errorf("got revision %+v, want %v replaced by %s", revisions[0], tt.old.Temperature, tt.new.Source)
+			}
+		})
+	}
+}
+
+func TestUpsertKeepsObservationsOfKeptValue(t *testing.T) {
+	forEachBackend(t, testUpsertKeepsObservationsOfKeptValue)
+}
+
+func testUpsertKeepsObservationsOfKeptValue(t *testing.T, b backend) {
+	repository := openRepository(t, b)
+	repository.SetUpsertPolicy(KeepFirstPolicy, nil)
+	date := day(2022, time.January, 5)
+	hour := date.Add(3 * time.Hour)
+	withObservation := func(temperature float32) *TemperatureEntity {
+		return &TemperatureEntity{
+			Department:  "Чита",
+			Date:        date,
+			Temperature: temperature,
+			Observations: NewObservationEntities("Чита", []weather.Observation{
+				{Coordinates: weather.Coordinates{Lat: 52, Lon: 113.5}, Time: hour, Temperature: temperature},
+			}),
+		}
+	}
+
+	if err := repository.Save(withObservation(-25)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Save(withObservation(-20)); err != nil {
+		t.Fatal(err)
+	}
+
+	next := date.AddDate(0, 0, 1)
+	observations, err := repository.FindObservations("Чита", &date, &next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 1 || observations[0].Temperature != -25 {
+		t.Errorf("got %+v, want the observation of the kept value", observations)
+	}
+}
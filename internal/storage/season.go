@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// HeatingSeasonEntity - текущее состояние отопительного периода филиала.
+// Since - день, которым подтверждено последнее изменение состояния.
+type HeatingSeasonEntity struct {
+	Department string `gorm:"primaryKey;autoIncrement:false"`
+	Active     bool
+	Since      time.Time
+}
+
+type HeatingSeasonRepository interface {
+	SaveHeatingSeason(s *HeatingSeasonEntity) error
+	FindHeatingSeason(department string) (*HeatingSeasonEntity, error)
+}
+
+func (repository *GormRepository) SaveHeatingSeason(season *HeatingSeasonEntity) error {
+	return repository.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(season).Error
+}
+
+// FindHeatingSeason возвращает nil, если состояние филиала еще не определялось.
+func (repository *GormRepository) FindHeatingSeason(department string) (*HeatingSeasonEntity, error) {
+	var seasons []*HeatingSeasonEntity
+	err := repository.db.Where("department = ?", department).Limit(1).Find(&seasons).Error
+	if err != nil || len(seasons) == 0 {
+		return nil, err
+	}
+	return seasons[0], nil
+}
@@ -6,15 +6,39 @@ import (
 )
 
 type Storage interface {
-	SaveTemperatureByLastDay(temperature []*weather.Temperature) error
+	SaveTemperatureByDate(date *time.Time, temperature []*weather.Temperature) error
 	GetAllTemperature() []*TemperatureEntity
+	GetLastDates() (map[string]time.Time, error)
+	GetStoredDates(department string, from *time.Time, to *time.Time) (map[time.Time]bool, error)
+	GetObservations(department string, from *time.Time, to *time.Time) ([]weather.Observation, error)
+	SaveForecasts(forecasts []*weather.Forecast) error
+	GetForecasts(department string, from *time.Time, to *time.Time) ([]*ForecastEntity, error)
+	GetTemperatures(department string, from *time.Time, to *time.Time) ([]*TemperatureEntity, error)
+	SaveHeatingSeason(season *HeatingSeasonEntity) error
+	GetHeatingSeason(department string) (*HeatingSeasonEntity, error)
+	GetNormals(department string, excludeYear int) ([]*Normal, error)
+	GetGaps(department string, from *time.Time, to *time.Time) ([]*Gap, error)
+	SaveTemperatures(temperatures []*TemperatureEntity) error
+	SaveQuarantine(quarantine []*QuarantineEntity) error
+	GetQuarantine(department string, from *time.Time, to *time.Time) ([]*QuarantineEntity, error)
+	GetTemperaturesByProvenance(filter *ProvenanceFilter) ([]*TemperatureEntity, error)
+	GetSources(department string) ([]string, error)
+	GetRevisions(department string, from *time.Time, to *time.Time) ([]*RevisionEntity, error)
+	QueryTemperatures(q *TemperatureQuery) ([]*TemperatureEntity, error)
+	CountTemperatures(q *TemperatureQuery) (int64, error)
+	AggregateTemperatures(q *TemperatureQuery, period Period) ([]*Aggregate, error)
+	IterateTemperatures(q *TemperatureQuery) (*TemperatureIterator, error)
+	SaveAPIKey(k *APIKeyEntity) error
+	GetAPIKey(hash string) (*APIKeyEntity, error)
+	GetAPIKeys() ([]*APIKeyEntity, error)
+	RevokeAPIKey(id uint) (bool, error)
 }
 
 type DBStorage struct {
-	repo TemperatureRepository
+	repo Repository
 }
 
-func NewDBStorage(repo TemperatureRepository) Storage {
+func NewDBStorage(repo Repository) Storage {
 	return &DBStorage{repo: repo}
 }
 
@@ -22,21 +46,138 @@ func (storage *DBStorage) GetAllTemperature() []*TemperatureEntity {
 	return storage.repo.FindAll()
 }
 
-func (storage *DBStorage) SaveTemperatureByLastDay(temperature []*weather.Temperature) error {
-	entities := createEntities(temperature)
+func (storage *DBStorage) GetLastDates() (map[string]time.Time, error) {
+	return storage.repo.FindLastDates()
+}
+
+func (storage *DBStorage) GetStoredDates(department string, from *time.Time, to *time.Time) (map[time.Time]bool, error) {
+	dates, err := storage.repo.FindDates(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[time.Time]bool, len(dates))
+	for _, date := range dates {
+		stored[date] = true
+	}
+	return stored, nil
+}
+
+func (storage *DBStorage) GetObservations(department string, from *time.Time, to *time.Time) ([]weather.Observation, error) {
+	entities, err := storage.repo.FindObservations(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	observations := make([]weather.Observation, 0, len(entities))
+	for _, entity := range entities {
+		observations = append(observations, entity.Observation())
+	}
+	return observations, nil
+}
+
+func (storage *DBStorage) SaveForecasts(forecasts []*weather.Forecast) error {
+	return storage.repo.SaveForecasts(NewForecastEntities(forecasts))
+}
+
+func (storage *DBStorage) GetForecasts(department string, from *time.Time, to *time.Time) ([]*ForecastEntity, error) {
+	return storage.repo.FindForecasts(department, from, to)
+}
+
+func (storage *DBStorage) GetTemperatures(department string, from *time.Time, to *time.Time) ([]*TemperatureEntity, error) {
+	return storage.repo.FindTemperatures(department, from, to)
+}
+
+func (storage *DBStorage) SaveHeatingSeason(season *HeatingSeasonEntity) error {
+	return storage.repo.SaveHeatingSeason(season)
+}
+
+func (storage *DBStorage) GetHeatingSeason(department string) (*HeatingSeasonEntity, error) {
+	return storage.repo.FindHeatingSeason(department)
+}
+
+func (storage *DBStorage) GetNormals(department string, excludeYear int) ([]*Normal, error) {
+	return storage.repo.FindNormals(department, excludeYear)
+}
+
+func (storage *DBStorage) SaveTemperatures(temperatures []*TemperatureEntity) error {
+	if len(temperatures) == 0 {
+		return nil
+	}
+	return storage.repo.SaveAll(temperatures)
+}
+
+func (storage *DBStorage) SaveQuarantine(quarantine []*QuarantineEntity) error {
+	return storage.repo.SaveQuarantine(quarantine)
+}
+
+func (storage *DBStorage) GetQuarantine(department string, from *time.Time, to *time.Time) ([]*QuarantineEntity, error) {
+	return storage.repo.FindQuarantine(department, from, to)
+}
+
+func (storage *DBStorage) GetTemperaturesByProvenance(filter *ProvenanceFilter) ([]*TemperatureEntity, error) {
+	return storage.repo.FindByProvenance(filter)
+}
+
+func (storage *DBStorage) GetSources(department string) ([]string, error) {
+	return storage.repo.FindSources(department)
+}
+
+func (storage *DBStorage) GetRevisions(department string, from *time.Time, to *time.Time) ([]*RevisionEntity, error) {
+	return storage.repo.FindRevisions(department, from, to)
+}
+
+func (storage *DBStorage) QueryTemperatures(q *TemperatureQuery) ([]*TemperatureEntity, error) {
+	return storage.repo.QueryTemperatures(q)
+}
+
+func (storage *DBStorage) CountTemperatures(q *TemperatureQuery) (int64, error) {
+	return storage.repo.CountTemperatures(q)
+}
+
+func (storage *DBStorage) AggregateTemperatures(q *TemperatureQuery, period Period) ([]*Aggregate, error) {
+	return storage.repo.AggregateTemperatures(q, period)
+}
+
+// IterateTemperatures возвращает итератор для выгрузок, которые не помещаются в память.
+func (storage *DBStorage) IterateTemperatures(q *TemperatureQuery) (*TemperatureIterator, error) {
+	return storage.repo.IterateTemperatures(q)
+}
+
+func (storage *DBStorage) SaveAPIKey(k *APIKeyEntity) error {
+	return storage.repo.SaveAPIKey(k)
+}
+
+func (storage *DBStorage) GetAPIKey(hash string) (*APIKeyEntity, error) {
+	return storage.repo.FindAPIKey(hash)
+}
+
+func (storage *DBStorage) GetAPIKeys() ([]*APIKeyEntity, error) {
+	return storage.repo.FindAPIKeys()
+}
+
+func (storage *DBStorage) RevokeAPIKey(id uint) (bool, error) {
+	return storage.repo.RevokeAPIKey(id, time.Now())
+}
+
+// SaveTemperatureByDate сохраняет суточные значения вместе с почасовыми наблюдениями, по которым они рассчитаны.
+func (storage *DBStorage) SaveTemperatureByDate(date *time.Time, temperature []*weather.Temperature) error {
+	entities := createEntities(date, temperature)
 	return storage.repo.SaveAll(entities)
 }
 
-func createEntities(t []*weather.Temperature) []*TemperatureEntity {
+func createEntities(date *time.Time, t []*weather.Temperature) []*TemperatureEntity {
 	entities := make([]*TemperatureEntity, 0, len(t))
 	for _, temperature := range t {
-		date := time.Now().AddDate(0, 0, -1)
 		item := TemperatureEntity{
-			Temperature: temperature.Value,
-			Department:  temperature.Location.Description,
-			Day:         date.Day(),
-			Month:       int(date.Month()),
-			Year:        date.Year(),
+			Temperature:  temperature.Value,
+			Min:          temperature.Min,
+			Max:          temperature.Max,
+			Department:   temperature.Location.Description,
+			Date:         dateOf(date),
+			Source:       temperature.Source,
+			IngestedAt:   time.Now().UTC(),
+			Aggregation:  string(temperature.Aggregation),
+			Samples:      len(temperature.Observations),
+			Observations: NewObservationEntities(temperature.Location.Description, temperature.Observations),
 		}
 		entities = append(entities, &item)
 	}
@@ -1,61 +1,182 @@
 package storage
 
 import (
+	"fmt"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"time"
 )
 
 type TemperatureEntity struct {
 	Temperature float32
-	Department  string `gorm:"primaryKey;autoIncrement:false"`
-	Day         int    `gorm:"primaryKey;autoIncrement:false"`
-	Month       int    `gorm:"primaryKey;autoIncrement:false"`
-	Year        int    `gorm:"primaryKey;autoIncrement:false"`
+	Min         float32
+	Max         float32
+	Department  string    `gorm:"primaryKey;autoIncrement:false"`
+	Date        time.Time `gorm:"type:date;primaryKey;autoIncrement:false;index"`
+	// Estimated - значение восстановлено по соседним дням или филиалам, а не получено из источника.
+	Estimated bool
+	// Source - провайдеры погоды, файл архива или метод восстановления, из которых получено значение.
+	Source      string `gorm:"index"`
+	IngestedAt  time.Time
+	Aggregation string
+	Samples     int
+	// Observations - почасовые наблюдения дня. Сохраняются только вместе со значением, принятым по политике обновления.
+	Observations []*ObservationEntity `gorm:"-"`
 }
 
 type TemperatureRepository interface {
 	Init() error
+	SetUpsertPolicy(policy UpsertPolicy, priorities []string)
 	Save(t *TemperatureEntity) error
 	SaveAll(t []*TemperatureEntity) error
 	FindAll() []*TemperatureEntity
+	FindLastDates() (map[string]time.Time, error)
+	FindDates(department string, from *time.Time, to *time.Time) ([]time.Time, error)
+	FindTemperatures(department string, from *time.Time, to *time.Time) ([]*TemperatureEntity, error)
 }
 
-type SQLiteRepository struct {
-	db   *gorm.DB
-	path string
+type Repository interface {
+	TemperatureRepository
+	ObservationRepository
+	ForecastRepository
+	HeatingSeasonRepository
+	NormalRepository
+	QuarantineRepository
+	ProvenanceRepository
+	RevisionRepository
+	QueryRepository
+	APIKeyRepository
 }
 
-func New(path *string) TemperatureRepository {
-	return &SQLiteRepository{
-		path: *path,
+const (
+	SQLiteDriver   = "sqlite"
+	PostgresDriver = "postgres"
+)
+
+// GormRepository хранит данные в SQLite или PostgreSQL, диалект определяется при создании.
+type GormRepository struct {
+	db         *gorm.DB
+	dialector  gorm.Dialector
+	policy     UpsertPolicy
+	priorities []string
+}
+
+func New(path *string) Repository {
+	return &GormRepository{
+		dialector: sqlite.Open(*path),
+		policy:    OverwritePolicy,
 	}
 }
 
-func (repository *SQLiteRepository) Init() error {
-	db, err := gorm.Open(sqlite.Open(repository.path), &gorm.Config{
+func NewPostgres(dsn *string) Repository {
+	return &GormRepository{
+		dialector: postgres.Open(*dsn),
+		policy:    OverwritePolicy,
+	}
+}
+
+// Open создает репозиторий для driver. Для SQLite dsn - путь к файлу БД, пустой driver означает SQLite.
+func Open(driver string, dsn *string) (Repository, error) {
+	switch driver {
+	case SQLiteDriver, "":
+		return New(dsn), nil
+	case PostgresDriver:
+		return NewPostgres(dsn), nil
+	default:
+		return nil, fmt.Errorf("Неизвестный драйвер БД: %s", driver)
+	}
+}
+
+// SetUpsertPolicy задает поведение Save и SaveAll для уже сохраненных дней.
+// priorities - провайдеры или файлы архива от более надежного к менее надежному, используются политикой priority.
+func (repository *GormRepository) SetUpsertPolicy(policy UpsertPolicy, priorities []string) {
+	repository.policy = policy
+	repository.priorities = priorities
+}
+
+func (repository *GormRepository) Init() error {
+	db, err := gorm.Open(repository.dialector, &gorm.Config{
 		CreateBatchSize: 20,
 	})
 	if err != nil {
 		return err
 	}
-	err = db.AutoMigrate(&TemperatureEntity{})
-	if err != nil {
+	if err := migrate(db); err != nil {
 		return err
 	}
 	repository.db = db
 	return nil
 }
 
-func (repository *SQLiteRepository) Save(t *TemperatureEntity) error {
-	return repository.db.Create(t).Error
+func (repository *GormRepository) Save(t *TemperatureEntity) error {
+	return repository.SaveAll([]*TemperatureEntity{t})
 }
 
-func (repository *SQLiteRepository) SaveAll(temperature []*TemperatureEntity) error {
-	return repository.db.Create(temperature).Error
+// SaveAll сохраняет значения по политике обновления. Замененные значения переносятся в таблицу ревизий.
+func (repository *GormRepository) SaveAll(temperature []*TemperatureEntity) error {
+	return repository.db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range temperature {
+			if err := repository.upsert(tx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (repository *SQLiteRepository) FindAll() []*TemperatureEntity {
+func (repository *GormRepository) FindAll() []*TemperatureEntity {
 	var temps []*TemperatureEntity
-	repository.db.Order("year, month, day, department").Find(&temps)
+	repository.db.Order("date, department").Find(&temps)
 	return temps
 }
+
+func (repository *GormRepository) FindLastDates() (map[string]time.Time, error) {
+	var temps []*TemperatureEntity
+	err := repository.db.
+		Where("date = (SELECT MAX(last.date) FROM temperature_entities AS last WHERE last.department = temperature_entities.department)").
+		Find(&temps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make(map[string]time.Time, len(temps))
+	for _, t := range temps {
+		dates[t.Department] = t.Date.UTC()
+	}
+	return dates, nil
+}
+
+func (repository *GormRepository) FindDates(department string, from *time.Time, to *time.Time) ([]time.Time, error) {
+	var temps []*TemperatureEntity
+	err := repository.db.
+		Where("department = ?", department).
+		Where("date BETWEEN ? AND ?", dateOf(from), dateOf(to)).
+		Order("date").
+		Find(&temps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]time.Time, 0, len(temps))
+	for _, t := range temps {
+		dates = append(dates, t.Date.UTC())
+	}
+	return dates, nil
+}
+
+// FindTemperatures возвращает суточные значения за [from, to]. Пустой department означает все филиалы.
+func (repository *GormRepository) FindTemperatures(department string, from *time.Time, to *time.Time) ([]*TemperatureEntity, error) {
+	var temps []*TemperatureEntity
+	query := repository.db.Where("date BETWEEN ? AND ?", dateOf(from), dateOf(to))
+	if department != "" {
+		query = query.Where("department = ?", department)
+	}
+	err := query.Order("date, department").Find(&temps).Error
+	return temps, err
+}
+
+// dateOf возвращает календарный день date как полночь UTC - в таком виде даты хранятся в БД.
+func dateOf(date *time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,276 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"temperature/internal/storage"
+	"temperature/internal/weather"
+	"time"
+)
+
+const (
+	DefaultMaxJump               float32 = 15
+	DefaultMaxNeighbourDeviation float32 = 10
+)
+
+const (
+	// neighbourWindow - сколько предыдущих дней используется для расчета обычной разницы с соседним филиалом.
+	neighbourWindow = 14
+	// neighbourMinDays - минимальное число общих дней, при котором сравнение с соседом имеет смысл.
+	neighbourMinDays = 5
+	// neighbourCount - сколько ближайших филиалов должны разойтись со значением, чтобы оно считалось подозрительным.
+	neighbourCount = 2
+)
+
+// Range - допустимый диапазон среднесуточной температуры для месяца.
+type Range struct {
+	Month int     `yaml:"month"`
+	Min   float32 `yaml:"min"`
+	Max   float32 `yaml:"max"`
+}
+
+// DefaultRanges - границы среднесуточной температуры по месяцам с запасом относительно рекордов Сибири.
+var DefaultRanges = []Range{
+	{Month: 1, Min: -60, Max: 10},
+	{Month: 2, Min: -60, Max: 12},
+	{Month: 3, Min: -50, Max: 20},
+	{Month: 4, Min: -35, Max: 30},
+	{Month: 5, Min: -20, Max: 37},
+	{Month: 6, Min: -10, Max: 42},
+	{Month: 7, Min: -5, Max: 42},
+	{Month: 8, Min: -8, Max: 40},
+	{Month: 9, Min: -20, Max: 35},
+	{Month: 10, Min: -35, Max: 27},
+	{Month: 11, Min: -50, Max: 18},
+	{Month: 12, Min: -60, Max: 12},
+}
+
+// Rules - настройки проверок. Незаданные значения заменяются значениями по умолчанию,
+// Ranges переопределяют DefaultRanges только для указанных месяцев.
+type Rules struct {
+	Ranges                []Range `yaml:"ranges,flow"`
+	MaxJump               float32 `yaml:"maxJump"`
+	MaxNeighbourDeviation float32 `yaml:"maxNeighbourDeviation"`
+}
+
+type Suspicious struct {
+	Temperature *weather.Temperature
+	Date        time.Time
+	Reasons     []string
+}
+
+// Validator проверяет суточные значения перед сохранением: диапазон для месяца,
+// скачок относительно предыдущего дня и расхождение с ближайшими филиалами.
+type Validator struct {
+	storage   storage.Storage
+	locations []weather.Location
+	ranges    map[int]Range
+	rules     Rules
+}
+
+func New(s storage.Storage, locations []weather.Location, rules Rules) *Validator {
+	ranges := make(map[int]Range, 12)
+	for _, r := range DefaultRanges {
+		ranges[r.Month] = r
+	}
+	for _, r := range rules.Ranges {
+		ranges[r.Month] = r
+	}
+	if rules.MaxJump <= 0 {
+		rules.MaxJump = DefaultMaxJump
+	}
+	if rules.MaxNeighbourDeviation <= 0 {
+		rules.MaxNeighbourDeviation = DefaultMaxNeighbourDeviation
+	}
+	return &Validator{
+		storage:   s,
+		locations: locations,
+		ranges:    ranges,
+		rules:     rules,
+	}
+}
+
+// Validate делит значения за date на прошедшие проверку и подозрительные.
+func (v *Validator) Validate(date *time.Time, temperatures []*weather.Temperature) ([]*weather.Temperature, []*Suspicious, error) {
+	incoming := make(map[string]float32, len(temperatures))
+	for _, t := range temperatures {
+		incoming[t.Location.Description] = t.Value
+	}
+
+	valid := make([]*weather.Temperature, 0, len(temperatures))
+	suspicious := make([]*Suspicious, 0)
+	for _, t := range temperatures {
+		reasons := make([]string, 0)
+		if reason, ok := v.checkRange(date, t); !ok {
+			reasons = append(reasons, reason)
+		}
+		reason, ok, err := v.checkJump(date, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			reasons = append(reasons, reason)
+		}
+		reason, ok, err = v.checkNeighbours(date, t, incoming)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			reasons = append(reasons, reason)
+		}
+
+		if len(reasons) == 0 {
+			valid = append(valid, t)
+		} else {
+			suspicious = append(suspicious, &Suspicious{Temperature: t, Date: *date, Reasons: reasons})
+		}
+	}
+	return valid, suspicious, nil
+}
+
+func (v *Validator) checkRange(date *time.Time, t *weather.Temperature) (string, bool) {
+	r, ok := v.ranges[int(date.Month())]
+	if !ok || (t.Value >= r.Min && t.Value <= r.Max) {
+		return "", true
+	}
+	return fmt.Sprintf("вне диапазона месяца [%0.0f; %0.0f]", r.Min, r.Max), false
+}
+
+func (v *Validator) checkJump(date *time.Time, t *weather.Temperature) (string, bool, error) {
+	previous := date.AddDate(0, 0, -1)
+	temps, err := v.storage.GetTemperatures(t.Location.Description, &previous, &previous)
+	if err != nil || len(temps) == 0 {
+		return "", true, err
+	}
+	jump := t.Value - temps[0].Temperature
+	if abs(jump) <= v.rules.MaxJump {
+		return "", true, nil
+	}
+	return fmt.Sprintf("скачок %+0.1fC относительно предыдущего дня", jump), false, nil
+}
+
+// checkNeighbours сравнивает разницу с ближайшими филиалами с их обычной разницей за последние дни.
+// Значение подозрительно, только если оно расходится со всеми проверенными соседями.
+func (v *Validator) checkNeighbours(date *time.Time, t *weather.Temperature, incoming map[string]float32) (string, bool, error) {
+	from := date.AddDate(0, 0, -neighbourWindow)
+	to := date.AddDate(0, 0, -1)
+	own, err := v.series(t.Location.Description, &from, &to)
+	if err != nil {
+		return "", true, err
+	}
+
+	deviations := make([]string, 0, neighbourCount)
+	for _, neighbour := range t.Location.Nearest(v.locations) {
+		if len(deviations) == neighbourCount {
+			break
+		}
+		value, ok, err := v.value(neighbour.Description, date, incoming)
+		if err != nil {
+			return "", true, err
+		}
+		if !ok {
+			continue
+		}
+		other, err := v.series(neighbour.Description, &from, &to)
+		if err != nil {
+			return "", true, err
+		}
+		offset, ok := meanOffset(own, other)
+		if !ok {
+			continue
+		}
+		deviation := t.Value - value - offset
+		if abs(deviation) <= v.rules.MaxNeighbourDeviation {
+			return "", true, nil
+		}
+		deviations = append(deviations, fmt.Sprintf("%s %+0.1fC", neighbour.Description, deviation))
+	}
+	if len(deviations) == 0 {
+		return "", true, nil
+	}
+	return fmt.Sprintf("расхождение с соседними филиалами: %s", strings.Join(deviations, ", ")), false, nil
+}
+
+func (v *Validator) value(department string, date *time.Time, incoming map[string]float32) (float32, bool, error) {
+	if value, ok := incoming[department]; ok {
+		return value, true, nil
+	}
+	temps, err := v.storage.GetTemperatures(department, date, date)
+	if err != nil || len(temps) == 0 {
+		return 0, false, err
+	}
+	return temps[0].Temperature, true, nil
+}
+
+func (v *Validator) series(department string, from *time.Time, to *time.Time) (map[time.Time]float32, error) {
+	temps, err := v.storage.GetTemperatures(department, from, to)
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[time.Time]float32, len(temps))
+	for _, t := range temps {
+		if !t.Estimated {
+			series[t.Date.UTC()] = t.Temperature
+		}
+	}
+	return series, nil
+}
+
+// Quarantine сохраняет подозрительные значения в карантин и возвращает те из них,
+// которые еще не были там с тем же значением, чтобы не повторять уведомление при каждом обновлении.
+func (v *Validator) Quarantine(suspicious []*Suspicious) ([]*Suspicious, error) {
+	fresh := make([]*Suspicious, 0, len(suspicious))
+	entities := make([]*storage.QuarantineEntity, 0, len(suspicious))
+	for _, s := range suspicious {
+		stored, err := v.storage.GetQuarantine(s.Temperature.Location.Description, &s.Date, &s.Date)
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) == 0 || stored[0].Temperature != s.Temperature.Value {
+			fresh = append(fresh, s)
+		}
+		entities = append(entities, &storage.QuarantineEntity{
+			Department:  s.Temperature.Location.Description,
+			Date:        s.Date,
+			Temperature: s.Temperature.Value,
+			Min:         s.Temperature.Min,
+			Max:         s.Temperature.Max,
+			Reasons:     strings.Join(s.Reasons, "; "),
+			CreatedAt:   time.Now().UTC(),
+		})
+	}
+	if err := v.storage.SaveQuarantine(entities); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+func NewQuarantineMessage(suspicious []*Suspicious) string {
+	builder := strings.Builder{}
+	builder.WriteString("Подозрительные значения помещены в карантин: \n\r")
+	for _, s := range suspicious {
+		builder.WriteString(fmt.Sprintf("%s %s - %0.1fC: %s; \n\r",
+			s.Temperature.Location.Description, s.Date.Format("02.01.2006"), s.Temperature.Value, strings.Join(s.Reasons, "; ")))
+	}
+	return builder.String()
+}
+
+func meanOffset(own map[time.Time]float32, other map[time.Time]float32) (float32, bool) {
+	var sum float32
+	n := 0
+	for date, value := range own {
+		if o, ok := other[date]; ok {
+			sum += value - o
+			n++
+		}
+	}
+	if n < neighbourMinDays {
+		return 0, false
+	}
+	return sum / float32(n), true
+}
+
+func abs(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
@@ -0,0 +1,131 @@
+package weather
+
+import (
+	"fmt"
+	"time"
+)
+
+type Aggregation string
+
+const (
+	MeanAggregation     Aggregation = "mean"
+	MinAggregation      Aggregation = "min"
+	MaxAggregation      Aggregation = "max"
+	SynopticAggregation Aggregation = "synoptic8"
+)
+
+const synopticInterval = 3 * time.Hour
+
+func ParseAggregation(s string) (Aggregation, error) {
+	switch aggregation := Aggregation(s); aggregation {
+	case MeanAggregation, MinAggregation, MaxAggregation, SynopticAggregation:
+		return aggregation, nil
+	case "":
+		return MeanAggregation, nil
+	default:
+		return "", fmt.Errorf("Неизвестный метод агрегации: %s", s)
+	}
+}
+
+type DailyTemperature struct {
+	Aggregation  Aggregation
+	Mean         float32
+	Min          float32
+	Max          float32
+	Observations []Observation
+}
+
+// Aggregate сворачивает наблюдения за сутки в одно значение.
+// synoptic8 - среднее по 8 синоптическим срокам 00, 03, ..., 21 UTC (методика Росгидромета).
+func (a Aggregation) Aggregate(observations []Observation) (float32, error) {
+	if len(observations) == 0 {
+		return 0, fmt.Errorf("Нет наблюдений для агрегации")
+	}
+	switch a {
+	case MinAggregation:
+		return minObservation(observations), nil
+	case MaxAggregation:
+		return maxObservation(observations), nil
+	case SynopticAggregation:
+		terms := synopticTerms(observations)
+		if len(terms) == 0 {
+			return 0, fmt.Errorf("Нет наблюдений в синоптические сроки")
+		}
+		return averageObservations(terms), nil
+	default:
+		return averageObservations(observations), nil
+	}
+}
+
+// Summarize возвращает значение по выбранному методу вместе с минимумом и максимумом за сутки.
+func (a Aggregation) Summarize(observations []Observation) (*DailyTemperature, error) {
+	mean, err := a.Aggregate(observations)
+	if err != nil {
+		return nil, err
+	}
+	return &DailyTemperature{
+		Aggregation:  a,
+		Mean:         mean,
+		Min:          minObservation(observations),
+		Max:          maxObservation(observations),
+		Observations: observations,
+	}, nil
+}
+
+func synopticTerms(observations []Observation) []Observation {
+	terms := make([]Observation, 0, 8)
+	seen := make(map[time.Time]bool, 8)
+	for _, o := range observations {
+		t := o.Time.UTC()
+		if t.Truncate(synopticInterval) != t || seen[t] {
+			continue
+		}
+		seen[t] = true
+		terms = append(terms, o)
+	}
+	return terms
+}
+
+func minObservation(observations []Observation) float32 {
+	min := observations[0].Temperature
+	for _, o := range observations {
+		if o.Temperature < min {
+			min = o.Temperature
+		}
+	}
+	return min
+}
+
+func maxObservation(observations []Observation) float32 {
+	max := observations[0].Temperature
+	for _, o := range observations {
+		if o.Temperature > max {
+			max = o.Temperature
+		}
+	}
+	return max
+}
+
+func averageDaily(items []*DailyTemperature) *DailyTemperature {
+	means := make([]float32, 0, len(items))
+	mins := make([]float32, 0, len(items))
+	maxs := make([]float32, 0, len(items))
+	observations := make([]Observation, 0, 24*len(items))
+	for _, item := range items {
+		means = append(means, item.Mean)
+		mins = append(mins, item.Min)
+		maxs = append(maxs, item.Max)
+		observations = append(observations, item.Observations...)
+	}
+	var aggregation Aggregation
+	if len(items) > 0 {
+		aggregation = items[0].Aggregation
+	}
+	return &DailyTemperature{
+		Aggregation:  aggregation,
+		Mean:         average(means),
+		Min:          average(mins),
+		Max:          average(maxs),
+		Observations: observations,
+	}
+}
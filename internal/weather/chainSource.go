@@ -0,0 +1,134 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEmptyChain = fmt.Errorf("Не задан ни один источник погоды")
+
+type ChainItem struct {
+	Name    string
+	Source  Source
+	Timeout time.Duration
+}
+
+type ChainError struct {
+	errors map[string]error
+	order  []string
+}
+
+func (e *ChainError) Error() string {
+	messages := make([]string, 0, len(e.order))
+	for _, name := range e.order {
+		messages = append(messages, fmt.Sprintf("%s: %s", name, e.errors[name]))
+	}
+	return fmt.Sprintf("Все источники погоды недоступны (%s)", strings.Join(messages, "; "))
+}
+
+func (e *ChainError) add(name string, err error) {
+	if e.errors == nil {
+		e.errors = make(map[string]error)
+	}
+	e.errors[name] = err
+	e.order = append(e.order, name)
+}
+
+// ChainSource опрашивает источники в заданном порядке и возвращает первый успешный ответ.
+// Для каждой координаты запоминается источник, который ответил последним.
+type ChainSource struct {
+	sources  []ChainItem
+	answered map[Coordinates]string
+	mutex    sync.Mutex
+}
+
+func NewChainSource(sources []ChainItem) *ChainSource {
+	return &ChainSource{
+		sources:  sources,
+		answered: make(map[Coordinates]string),
+	}
+}
+
+func (c *ChainSource) Init() error {
+	if len(c.sources) == 0 {
+		return ErrEmptyChain
+	}
+	chainErr := ChainError{}
+	for _, item := range c.sources {
+		if err := item.Source.Init(); err != nil {
+			chainErr.add(item.Name, err)
+		}
+	}
+	if len(chainErr.order) == len(c.sources) {
+		return &chainErr
+	}
+	return nil
+}
+
+func (c *ChainSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	if len(c.sources) == 0 {
+		return nil, ErrEmptyChain
+	}
+	chainErr := ChainError{}
+	for _, item := range c.sources {
+		observations, err := item.request(ctx, date, coordinate)
+		if err == nil {
+			c.setAnswered(coordinate, item.Name)
+			return observations, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		chainErr.add(item.Name, err)
+	}
+	return nil, &chainErr
+}
+
+func (c *ChainSource) MaxDepth() time.Duration {
+	return maxDepthOf(c.sources)
+}
+
+func (c *ChainSource) AnsweredBy(coordinate *Coordinates) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name, ok := c.answered[*coordinate]
+	return name, ok
+}
+
+func maxDepthOf(sources []ChainItem) time.Duration {
+	var depth time.Duration = 0
+	for _, item := range sources {
+		itemDepth := MaxDepth(item.Source)
+		if itemDepth == 0 {
+			return 0
+		}
+		if itemDepth > depth {
+			depth = itemDepth
+		}
+	}
+	return depth
+}
+
+func (item *ChainItem) request(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	if item.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, item.Timeout)
+		defer cancel()
+	}
+	observations, err := item.Source.GetWeatherByDate(ctx, date, coordinate)
+	if err != nil {
+		return nil, err
+	}
+	return filterDay(observations, date)
+}
+
+func (c *ChainSource) setAnswered(coordinate *Coordinates, name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.answered[*coordinate] = name
+}
@@ -0,0 +1,223 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"temperature/internal/notify"
+	"time"
+)
+
+type Strategy string
+
+const (
+	MedianStrategy  Strategy = "median"
+	MeanStrategy    Strategy = "mean"
+	PrimaryStrategy Strategy = "primary-with-check"
+)
+
+func ParseStrategy(s string) (Strategy, error) {
+	switch strategy := Strategy(s); strategy {
+	case MedianStrategy, MeanStrategy, PrimaryStrategy:
+		return strategy, nil
+	case "":
+		return MedianStrategy, nil
+	default:
+		return "", fmt.Errorf("Неизвестная стратегия объединения источников: %s", s)
+	}
+}
+
+type providerValue struct {
+	name  string
+	value float32
+}
+
+type providerObservations struct {
+	name         string
+	observations []Observation
+}
+
+// ConsensusSource опрашивает все источники параллельно и объединяет ответы по стратегии.
+// Если разброс значений превышает порог, отправляется предупреждение через notifier.
+type ConsensusSource struct {
+	sources   []ChainItem
+	strategy  Strategy
+	threshold float32
+	notifier  notify.Notifier
+	answered  map[Coordinates]string
+	mutex     sync.Mutex
+}
+
+func NewConsensusSource(sources []ChainItem, strategy Strategy, threshold float32, notifier notify.Notifier) *ConsensusSource {
+	return &ConsensusSource{
+		sources:   sources,
+		strategy:  strategy,
+		threshold: threshold,
+		notifier:  notifier,
+		answered:  make(map[Coordinates]string),
+	}
+}
+
+func (c *ConsensusSource) Init() error {
+	return NewChainSource(c.sources).Init()
+}
+
+func (c *ConsensusSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	if len(c.sources) == 0 {
+		return nil, ErrEmptyChain
+	}
+
+	results, chainErr := c.requestAll(ctx, date, coordinate)
+	if len(results) == 0 {
+		return nil, chainErr
+	}
+
+	means := make([]providerValue, 0, len(results))
+	for _, r := range results {
+		means = append(means, providerValue{name: r.name, value: averageObservations(r.observations)})
+	}
+
+	c.checkDisagreement(date, coordinate, means)
+	observations, used := c.combine(results)
+	c.setAnswered(coordinate, strings.Join(used, ","))
+	return observations, nil
+}
+
+func (c *ConsensusSource) MaxDepth() time.Duration {
+	return maxDepthOf(c.sources)
+}
+
+func (c *ConsensusSource) AnsweredBy(coordinate *Coordinates) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name, ok := c.answered[*coordinate]
+	return name, ok
+}
+
+func (c *ConsensusSource) requestAll(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]providerObservations, *ChainError) {
+	observations := make([][]Observation, len(c.sources))
+	errs := make([]error, len(c.sources))
+
+	wg := sync.WaitGroup{}
+	for i := range c.sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			observations[i], errs[i] = c.sources[i].request(ctx, date, coordinate)
+		}(i)
+	}
+	wg.Wait()
+
+	results := make([]providerObservations, 0, len(c.sources))
+	chainErr := ChainError{}
+	for i, item := range c.sources {
+		if errs[i] != nil {
+			chainErr.add(item.Name, errs[i])
+			continue
+		}
+		results = append(results, providerObservations{name: item.Name, observations: observations[i]})
+	}
+	return results, &chainErr
+}
+
+// combine объединяет ряды провайдеров почасово: для каждого срока наблюдения
+// значения разных провайдеров сводятся по выбранной стратегии.
+// Вместе с наблюдениями возвращаются провайдеры, значения которых вошли в результат.
+func (c *ConsensusSource) combine(results []providerObservations) ([]Observation, []string) {
+	if c.strategy == PrimaryStrategy && results[0].name == c.sources[0].Name {
+		return results[0].observations, []string{results[0].name}
+	}
+
+	combine := medianOf
+	if c.strategy == MeanStrategy {
+		combine = meanOf
+	}
+
+	byTime := make(map[time.Time][]providerValue)
+	variables := make(map[time.Time][]Variables)
+	times := make([]time.Time, 0, 24)
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.name)
+		for _, o := range r.observations {
+			key := o.Time.UTC()
+			if _, ok := byTime[key]; !ok {
+				times = append(times, key)
+			}
+			byTime[key] = append(byTime[key], providerValue{name: r.name, value: o.Temperature})
+			variables[key] = append(variables[key], o.Variables)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	combined := make([]Observation, 0, len(times))
+	for _, t := range times {
+		combined = append(combined, Observation{
+			Time:        t,
+			Temperature: combine(byTime[t]),
+			Variables:   combineVariables(variables[t], combine),
+		})
+	}
+	return combined, names
+}
+
+func (c *ConsensusSource) checkDisagreement(date *time.Time, coordinate *Coordinates, values []providerValue) {
+	if c.threshold <= 0 || c.notifier == nil || len(values) < 2 {
+		return
+	}
+	min, max := values[0].value, values[0].value
+	for _, v := range values {
+		if v.value < min {
+			min = v.value
+		}
+		if v.value > max {
+			max = v.value
+		}
+	}
+	if max-min <= c.threshold {
+		return
+	}
+	c.notifier.Emit(newDisagreementMessage(date, coordinate, values, max-min, c.threshold))
+}
+
+func (c *ConsensusSource) setAnswered(coordinate *Coordinates, name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.answered[*coordinate] = name
+}
+
+func meanOf(values []providerValue) float32 {
+	temps := make([]float32, 0, len(values))
+	for _, v := range values {
+		temps = append(temps, v.value)
+	}
+	return average(temps)
+}
+
+func medianOf(values []providerValue) float32 {
+	temps := make([]float32, 0, len(values))
+	for _, v := range values {
+		temps = append(temps, v.value)
+	}
+	sort.Slice(temps, func(i, j int) bool { return temps[i] < temps[j] })
+	middle := len(temps) / 2
+	if len(temps)%2 == 0 {
+		return (temps[middle-1] + temps[middle]) / 2
+	}
+	return temps[middle]
+}
+
+func newDisagreementMessage(date *time.Time, coordinate *Coordinates, values []providerValue, spread float32, threshold float32) string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("Внимание! Источники погоды расходятся для точки (%0.4f, %0.4f) за %s \n\r",
+		coordinate.Lat, coordinate.Lon, date.Format("02.01.2006")))
+	for _, v := range values {
+		builder.WriteString(fmt.Sprintf("%s - %0.1fC; \n\r", v.name, v.value))
+	}
+	builder.WriteString(fmt.Sprintf("Разброс %0.1fC при допустимом %0.1fC", spread, threshold))
+	return builder.String()
+}
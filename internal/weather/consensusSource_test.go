@@ -0,0 +1,132 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var consensusDate = time.Date(2022, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// stubSource отдает по одному наблюдению в час начиная с полуночи consensusDate.
+type stubSource struct {
+	temperatures []float32
+	err          error
+}
+
+func (s *stubSource) Init() error {
+	return nil
+}
+
+func (s *stubSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	observations := make([]Observation, 0, len(s.temperatures))
+	for i, temperature := range s.temperatures {
+		observations = append(observations, Observation{
+			Coordinates: *coordinate,
+			Time:        consensusDate.Add(time.Duration(i) * time.Hour),
+			Temperature: temperature,
+		})
+	}
+	return observations, nil
+}
+
+type recordingNotifier struct {
+	messages []string
+}
+
+func (n *recordingNotifier) Emit(message string) {
+	n.messages = append(n.messages, message)
+}
+
+func stubItems(sources ...*stubSource) []ChainItem {
+	names := []string{"first", "second", "third"}
+	items := make([]ChainItem, 0, len(sources))
+	for i, source := range sources {
+		items = append(items, ChainItem{Name: names[i], Source: source})
+	}
+	return items
+}
+
+func TestConsensusCombine(t *testing.T) {
+	failed := &stubSource{err: errors.New("unavailable")}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		sources  []*stubSource
+		want     []float32
+		answered string
+	}{
+		{"median of three", MedianStrategy, []*stubSource{{temperatures: []float32{1, 2}}, {temperatures: []float32{3, 4}}, {temperatures: []float32{8, 9}}}, []float32{3, 4}, "first,second,third"},
+		{"median of two is their mean", MedianStrategy, []*stubSource{{temperatures: []float32{1, 2}}, {temperatures: []float32{3, 6}}}, []float32{2, 4}, "first,second"},
+		{"mean of three", MeanStrategy, []*stubSource{{temperatures: []float32{1, 2}}, {temperatures: []float32{3, 4}}, {temperatures: []float32{8, 9}}}, []float32{4, 5}, "first,second,third"},
+		{"mean without the failed source", MeanStrategy, []*stubSource{failed, {temperatures: []float32{3, 4}}, {temperatures: []float32{7, 8}}}, []float32{5, 6}, "second,third"},
+		{"primary only", PrimaryStrategy, []*stubSource{{temperatures: []float32{1, 2}}, {temperatures: []float32{3, 4}}, {temperatures: []float32{8, 9}}}, []float32{1, 2}, "first"},
+		{"median of the rest when primary fails", PrimaryStrategy, []*stubSource{failed, {temperatures: []float32{3, 4}}, {temperatures: []float32{8, 9}}}, []float32{5.5, 6.5}, "second,third"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewConsensusSource(stubItems(tt.sources...), tt.strategy, 0, nil)
+			coordinates := &Coordinates{Lat: 52, Lon: 113.5}
+
+			observations, err := source.GetWeatherByDate(context.Background(), &consensusDate, coordinates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(observations) != len(tt.want) {
+				t.Fatalf("got %d observations, want %d", len(observations), len(tt.want))
+			}
+			for i, o := range observations {
+				if o.Temperature != tt.want[i] {
+					t.Errorf("hour %d: got %v, want %v", i, o.Temperature, tt.want[i])
+				}
+			}
+			if answered, _ := source.AnsweredBy(coordinates); answered != tt.answered {
+				t.Errorf("answered by %q, want %q", answered, tt.answered)
+			}
+		})
+	}
+}
+
+func TestConsensusFailsWhenAllSourcesFail(t *testing.T) {
+	failed := &stubSource{err: errors.New("unavailable")}
+	source := NewConsensusSource(stubItems(failed, failed), MedianStrategy, 0, nil)
+
+	if _, err := source.GetWeatherByDate(context.Background(), &consensusDate, &Coordinates{}); err == nil {
+		t.Fatal("got no error")
+	}
+}
+
+func TestConsensusDisagreementWarning(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold float32
+		means     []float32
+		warned    bool
+	}{
+		{"spread below threshold", 3, []float32{-20, -18}, false},
+		{"spread equal to threshold", 3, []float32{-20, -17}, false},
+		{"spread above threshold", 3, []float32{-20, -18, -16.5}, true},
+		{"zero threshold disables the check", 0, []float32{-20, 0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := make([]*stubSource, 0, len(tt.means))
+			for _, mean := range tt.means {
+				sources = append(sources, &stubSource{temperatures: []float32{mean - 1, mean + 1}})
+			}
+			notifier := &recordingNotifier{}
+			source := NewConsensusSource(stubItems(sources...), MedianStrategy, tt.threshold, notifier)
+
+			if _, err := source.GetWeatherByDate(context.Background(), &consensusDate, &Coordinates{}); err != nil {
+				t.Fatal(err)
+			}
+			if warned := len(notifier.messages) > 0; warned != tt.warned {
+				t.Errorf("warned %v, want %v: %v", warned, tt.warned, notifier.messages)
+			}
+		})
+	}
+}
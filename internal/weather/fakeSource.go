@@ -9,10 +9,17 @@ import (
 
 type FakeSource struct{}
 
-func (f FakeSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) (float32, error) {
-	v := rand.Float32()
-	if v > 0.8 {
-		return 0, fmt.Errorf("New Error")
+func (f FakeSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	if rand.Float32() > 0.8 {
+		return nil, fmt.Errorf("New Error")
 	}
-	return v, nil
+	start, _ := dayWindow(date)
+	observations := make([]Observation, 0, 24)
+	for i := 0; i < 24; i++ {
+		observations = append(observations, Observation{
+			Time:        start.Add(time.Duration(i) * time.Hour),
+			Temperature: rand.Float32(),
+		})
+	}
+	return observations, nil
 }
@@ -0,0 +1,174 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrForecastUnsupported = fmt.Errorf("Ни один источник погоды не поддерживает прогноз")
+
+type ForecastSource interface {
+	GetForecast(ctx context.Context, coordinate *Coordinates, days int) ([]Observation, error)
+}
+
+type ProviderForecast struct {
+	Provider     string
+	Observations []Observation
+}
+
+// MultiForecastSource возвращает прогнозы всех провайдеров, чтобы их точность можно было сравнить.
+type MultiForecastSource interface {
+	GetForecasts(ctx context.Context, coordinate *Coordinates, days int) ([]ProviderForecast, error)
+}
+
+type Forecast struct {
+	Location   *Location
+	Provider   string
+	IssuedAt   time.Time
+	TargetDate time.Time
+	LeadDays   int
+	DailyTemperature
+}
+
+func (c *ChainSource) GetForecasts(ctx context.Context, coordinate *Coordinates, days int) ([]ProviderForecast, error) {
+	return forecastsOf(ctx, c.sources, coordinate, days)
+}
+
+func (c *ConsensusSource) GetForecasts(ctx context.Context, coordinate *Coordinates, days int) ([]ProviderForecast, error) {
+	return forecastsOf(ctx, c.sources, coordinate, days)
+}
+
+func forecastsOf(ctx context.Context, sources []ChainItem, coordinate *Coordinates, days int) ([]ProviderForecast, error) {
+	forecasts := make([]ProviderForecast, 0, len(sources))
+	chainErr := ChainError{}
+	for _, item := range sources {
+		forecaster, ok := item.Source.(ForecastSource)
+		if !ok {
+			continue
+		}
+		observations, err := item.forecast(ctx, forecaster, coordinate, days)
+		if err != nil {
+			chainErr.add(item.Name, err)
+			continue
+		}
+		forecasts = append(forecasts, ProviderForecast{Provider: item.Name, Observations: observations})
+	}
+	if len(forecasts) == 0 {
+		if len(chainErr.order) == 0 {
+			return nil, ErrForecastUnsupported
+		}
+		return nil, &chainErr
+	}
+	return forecasts, nil
+}
+
+func (item *ChainItem) forecast(ctx context.Context, forecaster ForecastSource, coordinate *Coordinates, days int) ([]Observation, error) {
+	if item.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, item.Timeout)
+		defer cancel()
+	}
+	return forecaster.GetForecast(ctx, coordinate, days)
+}
+
+func (api *API) forecasts(ctx context.Context, coordinate *Coordinates, days int) ([]ProviderForecast, error) {
+	switch source := api.source.(type) {
+	case MultiForecastSource:
+		return source.GetForecasts(ctx, coordinate, days)
+	case ForecastSource:
+		observations, err := source.GetForecast(ctx, coordinate, days)
+		if err != nil {
+			return nil, err
+		}
+		provider := ""
+		if named, ok := api.source.(NamedSource); ok {
+			provider = named.Name()
+		}
+		return []ProviderForecast{{Provider: provider, Observations: observations}}, nil
+	default:
+		return nil, ErrForecastUnsupported
+	}
+}
+
+// ForecastByLocation возвращает суточный прогноз каждого провайдера на days дней после даты выпуска.
+// Сутки считаются по часовому поясу филиала, значения по точкам филиала усредняются.
+func (api *API) ForecastByLocation(ctx context.Context, issuedAt time.Time, locations *Location, days int) ([]*Forecast, error) {
+	loc, err := locations.TimeLocation()
+	if err != nil {
+		return nil, err
+	}
+	local := issuedAt.In(loc)
+	issueDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	byProvider := make(map[string][][]*DailyTemperature)
+	providers := make([]string, 0, 2)
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(locations.Coordinates))
+
+	for i, coordinates := range locations.Coordinates {
+		wg.Add(1)
+		go func(i int, coordinates Coordinates) {
+			defer wg.Done()
+			forecasts, err := api.forecasts(ctx, &coordinates, days+2)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, forecast := range forecasts {
+				daily := make([]*DailyTemperature, days)
+				for lead := 1; lead <= days; lead++ {
+					target := issueDay.AddDate(0, 0, lead)
+					observations, err := filterDay(forecast.Observations, &target)
+					if err != nil {
+						continue
+					}
+					for j := range observations {
+						observations[j].Coordinates = coordinates
+					}
+					daily[lead-1], _ = api.aggregation.Summarize(observations)
+				}
+				mutex.Lock()
+				if _, ok := byProvider[forecast.Provider]; !ok {
+					providers = append(providers, forecast.Provider)
+				}
+				byProvider[forecast.Provider] = append(byProvider[forecast.Provider], daily)
+				mutex.Unlock()
+			}
+		}(i, coordinates)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*Forecast, 0, len(providers)*days)
+	for _, provider := range providers {
+		for lead := 1; lead <= days; lead++ {
+			points := make([]*DailyTemperature, 0, len(locations.Coordinates))
+			for _, daily := range byProvider[provider] {
+				if daily[lead-1] != nil {
+					points = append(points, daily[lead-1])
+				}
+			}
+			if len(points) == 0 {
+				continue
+			}
+			target := issueDay.AddDate(0, 0, lead)
+			result = append(result, &Forecast{
+				Location:         locations,
+				Provider:         provider,
+				IssuedAt:         issuedAt,
+				TargetDate:       time.Date(target.Year(), target.Month(), target.Day(), 0, 0, 0, 0, time.UTC),
+				LeadDays:         lead,
+				DailyTemperature: *averageDaily(points),
+			})
+		}
+	}
+	return result, nil
+}
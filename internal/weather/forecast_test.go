@@ -0,0 +1,23 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestForecastOfSingleSourceHasProvider(t *testing.T) {
+	source, requested := openMeteoServer(t, http.StatusOK, "testdata/openmeteo_hourly.json")
+	api := &API{source: source, aggregation: MeanAggregation}
+
+	forecasts, err := api.forecasts(context.Background(), &Coordinates{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requested.Path != "/v1/forecast" || requested.Query().Get("forecast_days") != "3" {
+		t.Errorf("requested %s, want forecast for 3 days", requested)
+	}
+	if len(forecasts) != 1 || forecasts[0].Provider != OpenMeteoProvider {
+		t.Fatalf("got %+v, want one forecast of %s", forecasts, OpenMeteoProvider)
+	}
+}
@@ -0,0 +1,203 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OpenMeteoProvider - имя провайдера Open-Meteo в конфигурации.
+const OpenMeteoProvider = "openmeteo"
+
+const (
+	openMeteoURL                 = "https://archive-api.open-meteo.com"
+	openMeteoForecastURL         = "https://api.open-meteo.com"
+	openMeteoURLTemplate         = "%s/v1/archive?latitude=%f&longitude=%f&start_date=%s&end_date=%s&hourly=" + openMeteoHourlyParams + "&windspeed_unit=ms&timezone=GMT"
+	openMeteoForecastURLTemplate = "%s/v1/forecast?latitude=%f&longitude=%f&past_days=%d&forecast_days=%d&hourly=" + openMeteoHourlyParams + "&windspeed_unit=ms&timezone=GMT"
+	openMeteoHourlyParams        = "temperature_2m,relativehumidity_2m,precipitation,windspeed_10m,windgusts_10m,snow_depth"
+	openMeteoDateLayout          = "2006-01-02"
+	openMeteoTimeLayout          = "2006-01-02T15:04"
+	openMeteoMaxForecastDays     = 16
+	openMeteoMaxPastDays         = 92
+	// openMeteoArchiveLag - через сколько архив гарантированно содержит сутки: ERA5 публикуется
+	// с задержкой в несколько дней, более свежие сутки берутся из прогнозного API за прошедшие дни.
+	openMeteoArchiveLag = 7 * 24 * time.Hour
+)
+
+type OpenMeteoResponse struct {
+	Hourly OpenMeteoHourly `json:"hourly"`
+}
+
+type OpenMeteoHourly struct {
+	Time          []string   `json:"time"`
+	Temperature   []*float32 `json:"temperature_2m"`
+	Humidity      []*float32 `json:"relativehumidity_2m"`
+	Precipitation []*float32 `json:"precipitation"`
+	WindSpeed     []*float32 `json:"windspeed_10m"`
+	WindGust      []*float32 `json:"windgusts_10m"`
+	SnowDepth     []*float32 `json:"snow_depth"`
+}
+
+func (h *OpenMeteoHourly) variables(i int) Variables {
+	variables := Variables{
+		Humidity:      valueAt(h.Humidity, i),
+		Precipitation: valueAt(h.Precipitation, i),
+		WindSpeed:     valueAt(h.WindSpeed, i),
+		WindGust:      valueAt(h.WindGust, i),
+	}
+	if depth := valueAt(h.SnowDepth, i); depth != nil {
+		variables.SnowDepth = float32Ptr(*depth * 100)
+	}
+	return variables
+}
+
+func valueAt(values []*float32, i int) *float32 {
+	if i >= len(values) {
+		return nil
+	}
+	return values[i]
+}
+
+type OpenMeteoError struct {
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// OpenMeteoSource получает архивные данные (реанализ ERA5) и прогноз из Open-Meteo.
+// Последние openMeteoArchiveLag запрашиваются из прогнозного API с past_days.
+// Ключ доступа не требуется, url можно переопределить для зеркала или тестового сервера,
+// тогда архив и прогноз запрашиваются по одному адресу.
+type OpenMeteoSource struct {
+	url         string
+	forecastURL string
+	http        *http.Client
+	now         func() time.Time
+}
+
+func NewOpenMeteoAPI(url *string) Source {
+	api := OpenMeteoSource{
+		url:         openMeteoURL,
+		forecastURL: openMeteoForecastURL,
+		http: &http.Client{
+			Timeout: 10000 * time.Millisecond,
+		},
+		now: time.Now,
+	}
+	if url != nil && *url != "" {
+		api.url = *url
+		api.forecastURL = *url
+	}
+	return &api
+}
+
+func (w *OpenMeteoSource) Name() string {
+	return OpenMeteoProvider
+}
+
+func (w *OpenMeteoSource) Init() error {
+	date := time.Now().AddDate(0, 0, -1)
+	_, err := w.GetWeatherByDate(context.Background(), &date, &Coordinates{
+		Lon: 0,
+		Lat: 0,
+	})
+	if err != nil {
+		return fmt.Errorf("Не удалось установить соединенение с Open-Meteo по прочине: %s", err.Error())
+	}
+	return nil
+}
+
+func (w *OpenMeteoSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	start, end := dayWindow(date)
+	now := w.now()
+	var url string
+	if end.After(now.Add(-openMeteoArchiveLag)) {
+		today := utcDay(now)
+		past := int(today.Sub(utcDay(start)).Hours() / 24)
+		if past > openMeteoMaxPastDays {
+			return nil, fmt.Errorf("Open-Meteo не хранит прогноз старше %d дней", openMeteoMaxPastDays)
+		}
+		if past < 0 {
+			past = 0
+		}
+		days := int(utcDay(end.Add(-time.Second)).Sub(today).Hours()/24) + 1
+		if days < 1 {
+			days = 1
+		}
+		url = fmt.Sprintf(openMeteoForecastURLTemplate, w.forecastURL, coordinate.Lat, coordinate.Lon, past, days)
+	} else {
+		url = fmt.Sprintf(openMeteoURLTemplate, w.url, coordinate.Lat, coordinate.Lon,
+			start.UTC().Format(openMeteoDateLayout), end.Add(-time.Second).UTC().Format(openMeteoDateLayout))
+	}
+	observations, err := w.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return filterDay(observations, date)
+}
+
+// GetForecast возвращает почасовой прогноз на days суток вперед, включая текущие.
+func (w *OpenMeteoSource) GetForecast(ctx context.Context, coordinate *Coordinates, days int) ([]Observation, error) {
+	if days > openMeteoMaxForecastDays {
+		days = openMeteoMaxForecastDays
+	}
+	url := fmt.Sprintf(openMeteoForecastURLTemplate, w.forecastURL, coordinate.Lat, coordinate.Lon, 0, days)
+	return w.fetch(ctx, url)
+}
+
+func (w *OpenMeteoSource) fetch(ctx context.Context, url string) ([]Observation, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := w.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, extractOpenMeteoError(res)
+	}
+
+	data := OpenMeteoResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return extractOpenMeteoObservations(&data)
+}
+
+func extractOpenMeteoError(response *http.Response) error {
+	message := extractBody(response)
+	payload := OpenMeteoError{}
+	if err := json.Unmarshal([]byte(*message), &payload); err != nil || payload.Reason == "" {
+		return errors.New(*message)
+	}
+	return errors.New(payload.Reason)
+}
+
+func extractOpenMeteoObservations(payload *OpenMeteoResponse) ([]Observation, error) {
+	hourly := payload.Hourly
+	if len(hourly.Time) != len(hourly.Temperature) {
+		return nil, fmt.Errorf("Open-Meteo вернул массивы разной длины")
+	}
+	observations := make([]Observation, 0, len(hourly.Time))
+	for i, value := range hourly.Time {
+		if hourly.Temperature[i] == nil {
+			continue
+		}
+		t, err := time.ParseInLocation(openMeteoTimeLayout, value, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, Observation{
+			Time:        t,
+			Temperature: *hourly.Temperature[i],
+			Variables:   hourly.variables(i),
+		})
+	}
+	return observations, nil
+}
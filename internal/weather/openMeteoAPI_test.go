@@ -0,0 +1,154 @@
+package weather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// openMeteoServer отдает fixture на любой запрос и запоминает последний запрос.
+func openMeteoServer(t *testing.T, status int, fixture string) (*OpenMeteoSource, *url.URL) {
+	t.Helper()
+	body, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requested := &url.URL{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requested = *r.URL
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	source := NewOpenMeteoAPI(&server.URL).(*OpenMeteoSource)
+	source.now = func() time.Time {
+		return time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return source, requested
+}
+
+func TestOpenMeteoArchiveDay(t *testing.T) {
+	source, requested := openMeteoServer(t, http.StatusOK, "testdata/openmeteo_hourly.json")
+	date := time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	observations, err := source.GetWeatherByDate(context.Background(), &date, &Coordinates{Lat: 52, Lon: 113.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requested.Path != "/v1/archive" {
+		t.Errorf("path = %s, want /v1/archive", requested.Path)
+	}
+	query := requested.Query()
+	if query.Get("start_date") != "2022-01-04" || query.Get("end_date") != "2022-01-04" {
+		t.Errorf("start_date = %s, end_date = %s, want 2022-01-04", query.Get("start_date"), query.Get("end_date"))
+	}
+	if query.Get("timezone") != "GMT" {
+		t.Errorf("timezone = %s, want GMT", query.Get("timezone"))
+	}
+	if len(observations) != 24 {
+		t.Fatalf("got %d observations, want 24", len(observations))
+	}
+
+	first := observations[0]
+	if !first.Time.Equal(date) || first.Temperature != -20 {
+		t.Errorf("first = %s %v, want %s -20", first.Time, first.Temperature, date)
+	}
+	if first.Humidity == nil || *first.Humidity != 80 || first.WindGust == nil || *first.WindGust != 7.2 {
+		t.Errorf("variables were not parsed: %+v", first.Variables)
+	}
+	if first.SnowDepth == nil || *first.SnowDepth != 25 {
+		t.Errorf("snow depth = %v, want 25 cm", first.SnowDepth)
+	}
+	if last := observations[23]; last.Time.Hour() != 23 || last.Temperature != -8.5 {
+		t.Errorf("last = %s %v, want 23:00 -8.5", last.Time, last.Temperature)
+	}
+}
+
+func TestOpenMeteoSkipsNullHours(t *testing.T) {
+	source, _ := openMeteoServer(t, http.StatusOK, "testdata/openmeteo_hourly.json")
+	date := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	observations, err := source.GetWeatherByDate(context.Background(), &date, &Coordinates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 22 {
+		t.Fatalf("got %d observations, want 22 without null hours", len(observations))
+	}
+	for _, o := range observations {
+		if o.Time.Hour() == 6 || o.Time.Hour() == 7 {
+			t.Errorf("null hour %s was not skipped", o.Time)
+		}
+	}
+}
+
+// Сутки филиала в UTC+9 начинаются в 15:00 UTC предыдущего дня и захватывают две даты GMT.
+func TestOpenMeteoLocalDaySpansTwoGMTDates(t *testing.T) {
+	source, requested := openMeteoServer(t, http.StatusOK, "testdata/openmeteo_hourly.json")
+	chita, err := time.LoadLocation("Asia/Chita")
+	if err != nil {
+		t.Skip(err)
+	}
+	date := time.Date(2022, 1, 5, 0, 0, 0, 0, chita)
+
+	observations, err := source.GetWeatherByDate(context.Background(), &date, &Coordinates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := requested.Query()
+	if query.Get("start_date") != "2022-01-04" || query.Get("end_date") != "2022-01-05" {
+		t.Errorf("start_date = %s, end_date = %s, want 2022-01-04 and 2022-01-05", query.Get("start_date"), query.Get("end_date"))
+	}
+	if len(observations) != 22 {
+		t.Fatalf("got %d observations, want 22", len(observations))
+	}
+	start := time.Date(2022, 1, 4, 15, 0, 0, 0, time.UTC)
+	if !observations[0].Time.Equal(start) {
+		t.Errorf("first = %s, want %s", observations[0].Time, start)
+	}
+	if last := observations[len(observations)-1].Time; !last.Equal(start.Add(23 * time.Hour)) {
+		t.Errorf("last = %s, want %s", last, start.Add(23*time.Hour))
+	}
+}
+
+func TestOpenMeteoRecentDayUsesForecast(t *testing.T) {
+	source, requested := openMeteoServer(t, http.StatusOK, "testdata/openmeteo_hourly.json")
+	source.now = func() time.Time {
+		return time.Date(2022, 1, 6, 10, 0, 0, 0, time.UTC)
+	}
+	date := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	observations, err := source.GetWeatherByDate(context.Background(), &date, &Coordinates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requested.Path != "/v1/forecast" {
+		t.Errorf("path = %s, want /v1/forecast", requested.Path)
+	}
+	query := requested.Query()
+	if query.Get("past_days") != "1" || query.Get("forecast_days") != "1" {
+		t.Errorf("past_days = %s, forecast_days = %s, want 1 and 1", query.Get("past_days"), query.Get("forecast_days"))
+	}
+	if len(observations) != 22 {
+		t.Fatalf("got %d observations, want 22", len(observations))
+	}
+}
+
+func TestOpenMeteoError(t *testing.T) {
+	source, _ := openMeteoServer(t, http.StatusBadRequest, "testdata/openmeteo_error.json")
+	date := time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	_, err := source.GetWeatherByDate(context.Background(), &date, &Coordinates{})
+	if err == nil || err.Error() != "Parameter 'start_date' is out of allowed range" {
+		t.Errorf("err = %v, want reason from response", err)
+	}
+}
@@ -11,9 +11,12 @@ import (
 	"time"
 )
 
+// OpenWeatherProvider - имя провайдера OpenWeather в конфигурации.
+const OpenWeatherProvider = "openweather"
+
 const (
 	urlTemplate  = "https://api.openweathermap.org/data/2.5/onecall/timemachine?lat=%f&lon=%f&dt=%d&appid=%s&units=metric"
-	maxDaysSince = 3600000000000 * 24 * 4
+	maxDaysSince = 4 * 24 * time.Hour
 )
 
 type Response struct {
@@ -22,7 +25,17 @@ type Response struct {
 }
 
 type Info struct {
-	Temperature float32 `json:"temp"`
+	Dt          int64          `json:"dt"`
+	Temperature float32        `json:"temp"`
+	Humidity    *float32       `json:"humidity"`
+	WindSpeed   *float32       `json:"wind_speed"`
+	WindGust    *float32       `json:"wind_gust"`
+	Rain        *Precipitation `json:"rain"`
+	Snow        *Precipitation `json:"snow"`
+}
+
+type Precipitation struct {
+	OneHour float32 `json:"1h"`
 }
 
 type OpenWeatherSource struct {
@@ -52,28 +65,65 @@ func NewOpenWeatherAPI(token *string) Source {
 	return &api
 }
 
-func (w *OpenWeatherSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) (float32, error) {
-	if !validDate(date) {
-		return 0, fmt.Errorf("Превышена максимальная глубина поиска")
+func (w *OpenWeatherSource) GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error) {
+	start, end := dayWindow(date)
+	if !validDate(&start) {
+		return nil, fmt.Errorf("Превышена максимальная глубина поиска")
+	}
+
+	observations := make([]Observation, 0, 48)
+	for day := utcDay(start); day.Before(end); day = day.AddDate(0, 0, 1) {
+		data, err := w.requestDay(ctx, &day, coordinate)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range data.Hourly {
+			observations = append(observations, Observation{
+				Time:        time.Unix(info.Dt, 0).UTC(),
+				Temperature: info.Temperature,
+				Variables:   info.variables(),
+			})
+		}
+	}
+	return filterDay(observations, date)
+}
+
+func (info *Info) variables() Variables {
+	variables := Variables{
+		WindSpeed: info.WindSpeed,
+		WindGust:  info.WindGust,
+		Humidity:  info.Humidity,
+	}
+	if info.Rain != nil || info.Snow != nil {
+		var precipitation float32 = 0
+		if info.Rain != nil {
+			precipitation += info.Rain.OneHour
+		}
+		if info.Snow != nil {
+			precipitation += info.Snow.OneHour
+		}
+		variables.Precipitation = &precipitation
 	}
+	return variables
+}
 
-	request, err := w.prepareRequest(ctx, date, coordinate)
+func (w *OpenWeatherSource) requestDay(ctx context.Context, day *time.Time, coordinate *Coordinates) (*Response, error) {
+	request, err := w.prepareRequest(ctx, day, coordinate)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	res, err := w.http.Do(request)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer res.Body.Close()
+
 	if res.StatusCode != 200 {
 		message := extractBody(res)
-		return 0, errors.New(*message)
+		return nil, errors.New(*message)
 	}
-
-	defer res.Body.Close()
-	data, err := extractDataFromResponse(res)
-	return extractAverageTemperature(data), nil
+	return extractDataFromResponse(res)
 }
 
 func (w *OpenWeatherSource) prepareRequest(ctx context.Context, date *time.Time, coordinate *Coordinates) (*http.Request, error) {
@@ -81,9 +131,16 @@ func (w *OpenWeatherSource) prepareRequest(ctx context.Context, date *time.Time,
 	return http.NewRequestWithContext(ctx, "GET", url, nil)
 }
 
+func (w *OpenWeatherSource) Name() string {
+	return OpenWeatherProvider
+}
+
+func (w *OpenWeatherSource) MaxDepth() time.Duration {
+	return maxDaysSince
+}
+
 func validDate(date *time.Time) bool {
-	since := time.Since(*date)
-	return since.Hours() < maxDaysSince
+	return time.Since(*date) < maxDaysSince
 }
 
 func extractDataFromResponse(response *http.Response) (*Response, error) {
@@ -104,12 +161,3 @@ func extractBody(response *http.Response) *string {
 	str := builder.String()
 	return &str
 }
-
-func extractAverageTemperature(payload *Response) float32 {
-	var sumTemp float32 = 0.0
-	period := payload.Hourly[3:12]
-	for _, info := range period {
-		sumTemp += info.Temperature
-	}
-	return sumTemp / float32(len(period))
-}
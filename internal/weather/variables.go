@@ -0,0 +1,40 @@
+package weather
+
+// Variables - дополнительные метеопараметры наблюдения. nil означает, что провайдер значение не передал.
+// Единицы: ветер и порывы м/с, осадки мм, влажность %, высота снежного покрова см.
+type Variables struct {
+	WindSpeed     *float32
+	WindGust      *float32
+	Precipitation *float32
+	Humidity      *float32
+	SnowDepth     *float32
+}
+
+var variableFields = []func(v *Variables) **float32{
+	func(v *Variables) **float32 { return &v.WindSpeed },
+	func(v *Variables) **float32 { return &v.WindGust },
+	func(v *Variables) **float32 { return &v.Precipitation },
+	func(v *Variables) **float32 { return &v.Humidity },
+	func(v *Variables) **float32 { return &v.SnowDepth },
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+// combineVariables сводит дополнительные параметры нескольких провайдеров тем же способом, что и температуру.
+func combineVariables(items []Variables, combine func([]providerValue) float32) Variables {
+	result := Variables{}
+	for _, field := range variableFields {
+		values := make([]providerValue, 0, len(items))
+		for i := range items {
+			if value := *field(&items[i]); value != nil {
+				values = append(values, providerValue{value: *value})
+			}
+		}
+		if len(values) > 0 {
+			*field(&result) = float32Ptr(combine(values))
+		}
+	}
+	return result
+}
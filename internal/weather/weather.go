@@ -3,6 +3,8 @@ package weather
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -12,19 +14,108 @@ type Coordinates struct {
 	Lat float32 `yaml:"lat"`
 }
 
+// Source возвращает почасовые наблюдения за сутки, которые начинаются в полночь date
+// в часовом поясе date.Location().
 type Source interface {
 	Init() error
-	GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) (float32, error)
+	GetWeatherByDate(ctx context.Context, date *time.Time, coordinate *Coordinates) ([]Observation, error)
+}
+
+type Observation struct {
+	Coordinates Coordinates
+	Time        time.Time
+	Temperature float32
+	Variables
+}
+
+// RecordingSource запоминает, какой провайдер ответил по каждой координате.
+type RecordingSource interface {
+	Source
+	AnsweredBy(coordinate *Coordinates) (string, bool)
+}
+
+// NamedSource реализуют источники, которые знают имя своего провайдера в конфигурации.
+type NamedSource interface {
+	Name() string
+}
+
+// DepthLimitedSource реализуют источники, которые хранят историю ограниченное время.
+type DepthLimitedSource interface {
+	MaxDepth() time.Duration
+}
+
+// MaxDepth возвращает максимальную глубину запроса источника, 0 - без ограничений.
+func MaxDepth(s Source) time.Duration {
+	if limited, ok := s.(DepthLimitedSource); ok {
+		return limited.MaxDepth()
+	}
+	return 0
 }
 
 type Location struct {
 	Description string        `yaml:"description"`
+	Timezone    string        `yaml:"timezone"`
 	Coordinates []Coordinates `yaml:"coordinates,flow"`
 }
 
+func (l *Location) TimeLocation() (*time.Location, error) {
+	if l.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(l.Timezone)
+}
+
+// LocalDay возвращает полночь календарного дня date в часовом поясе филиала.
+func (l *Location) LocalDay(date *time.Time) (time.Time, error) {
+	loc, err := l.TimeLocation()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// Nearest возвращает остальные филиалы из locations в порядке удаления от l.
+func (l *Location) Nearest(locations []Location) []Location {
+	nearest := make([]Location, 0, len(locations))
+	for _, location := range locations {
+		if location.Description != l.Description && len(location.Coordinates) > 0 {
+			nearest = append(nearest, location)
+		}
+	}
+	lon, lat := l.centroid()
+	sort.Slice(nearest, func(i, j int) bool {
+		return nearest[i].distance(lon, lat) < nearest[j].distance(lon, lat)
+	})
+	return nearest
+}
+
+func (l *Location) centroid() (float64, float64) {
+	if len(l.Coordinates) == 0 {
+		return 0, 0
+	}
+	var lon, lat float64
+	for _, c := range l.Coordinates {
+		lon += float64(c.Lon)
+		lat += float64(c.Lat)
+	}
+	n := float64(len(l.Coordinates))
+	return lon / n, lat / n
+}
+
+func (l *Location) distance(lon float64, lat float64) float64 {
+	l0, t0 := l.centroid()
+	return math.Hypot((l0-lon)*math.Cos(lat*math.Pi/180), t0-lat)
+}
+
 type Temperature struct {
-	Location *Location
-	Value    float32
+	Location     *Location
+	Value        float32
+	Min          float32
+	Max          float32
+	Observations []Observation
+	// Source - провайдеры, ответившие по точкам филиала.
+	Source      string
+	Aggregation Aggregation
 }
 
 type Result struct {
@@ -32,39 +123,82 @@ type Result struct {
 	Err         error
 }
 
+func NewTemperature(location *Location, daily *DailyTemperature) *Temperature {
+	return &Temperature{
+		Location:     location,
+		Value:        daily.Mean,
+		Min:          daily.Min,
+		Max:          daily.Max,
+		Observations: daily.Observations,
+		Aggregation:  daily.Aggregation,
+	}
+}
+
 type API struct {
-	source Source
+	source      Source
+	aggregation Aggregation
 }
 
-func New(s Source) (*API, error) {
-	weather := API{source: s}
+func New(s Source, aggregation Aggregation) (*API, error) {
+	weather := API{source: s, aggregation: aggregation}
 	err := weather.checkConnection()
 	return &weather, err
 }
 
 func (api *API) checkConnection() error {
-	_, err := api.TemperatureOfDay(context.Background(), &Coordinates{
+	date := time.Now().AddDate(0, 0, -1)
+	_, err := api.TemperatureOfDate(context.Background(), &date, &Coordinates{
 		Lon: 0,
 		Lat: 0,
 	})
 	if err != nil {
-		return fmt.Errorf("Не удалось установить соединенение с OpenWeather.com по прочине: %s", err.Error())
+		return fmt.Errorf("Не удалось установить соединенение с источником погоды по прочине: %s", err.Error())
 	}
 	return nil
 }
 
-func (api *API) TemperatureOfDay(ctx context.Context, coordinates *Coordinates) (float32, error) {
+func (api *API) MaxDepth() time.Duration {
+	return MaxDepth(api.source)
+}
+
+func (api *API) Aggregation() Aggregation {
+	return api.aggregation
+}
+
+func (api *API) TemperatureOfDay(ctx context.Context, coordinates *Coordinates) (*DailyTemperature, error) {
 	date := time.Now().AddDate(0, 0, -1)
-	return api.source.GetWeatherByDate(ctx, &date, coordinates)
+	return api.TemperatureOfDate(ctx, &date, coordinates)
 }
 
-func (api *API) TemperatureOfDayByLocation(ctx context.Context, locations *Location) (float32, error) {
-	temps := make([]float32, 0, 5)
+func (api *API) TemperatureOfDayByLocation(ctx context.Context, locations *Location) (*DailyTemperature, error) {
+	date := time.Now().AddDate(0, 0, -1)
+	return api.TemperatureOfDateByLocation(ctx, &date, locations)
+}
+
+func (api *API) TemperatureOfDate(ctx context.Context, date *time.Time, coordinates *Coordinates) (*DailyTemperature, error) {
+	observations, err := api.source.GetWeatherByDate(ctx, date, coordinates)
+	if err != nil {
+		return nil, err
+	}
+	observations, err = filterDay(observations, date)
+	if err != nil {
+		return nil, err
+	}
+	for i := range observations {
+		observations[i].Coordinates = *coordinates
+	}
+	return api.aggregation.Summarize(observations)
+}
 
-	resultsChan := make(chan float32, len(locations.Coordinates))
-	errorsChan := make(chan error)
+func (api *API) TemperatureOfDateByLocation(ctx context.Context, date *time.Time, locations *Location) (*DailyTemperature, error) {
+	localDay, err := locations.LocalDay(date)
+	if err != nil {
+		return nil, err
+	}
+	temps := make([]*DailyTemperature, 0, 5)
 
-	defer close(errorsChan)
+	resultsChan := make(chan *DailyTemperature, len(locations.Coordinates))
+	errorsChan := make(chan error, len(locations.Coordinates))
 
 	wg := sync.WaitGroup{}
 	apiCtx, cancel := context.WithCancel(ctx)
@@ -74,7 +208,7 @@ func (api *API) TemperatureOfDayByLocation(ctx context.Context, locations *Locat
 		for _, coordinates := range locations.Coordinates {
 			wg.Add(1)
 			go func(coordinates Coordinates) {
-				temp, err := api.TemperatureOfDay(apiCtx, &coordinates)
+				temp, err := api.TemperatureOfDate(apiCtx, &localDay, &coordinates)
 				if err != nil {
 					errorsChan <- err
 				} else {
@@ -90,13 +224,13 @@ func (api *API) TemperatureOfDayByLocation(ctx context.Context, locations *Locat
 		select {
 		case result, ok := <-resultsChan:
 			if !ok {
-				return average(temps), nil
+				return averageDaily(temps), nil
 			}
 			temps = append(temps, result)
 		case err := <-errorsChan:
-			return 0, err
+			return nil, err
 		case <-ctx.Done():
-			return 0, nil
+			return nil, ctx.Err()
 		}
 	}
 }
@@ -108,3 +242,36 @@ func average(items []float32) float32 {
 	}
 	return sum / float32(len(items))
 }
+
+func averageObservations(observations []Observation) float32 {
+	temps := make([]float32, 0, len(observations))
+	for _, o := range observations {
+		temps = append(temps, o.Temperature)
+	}
+	return average(temps)
+}
+
+// dayWindow возвращает границы суток [start, end), к которым относится date.
+func dayWindow(date *time.Time) (time.Time, time.Time) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func filterDay(observations []Observation, date *time.Time) ([]Observation, error) {
+	start, end := dayWindow(date)
+	result := make([]Observation, 0, 24)
+	for _, o := range observations {
+		if !o.Time.Before(start) && o.Time.Before(end) {
+			result = append(result, o)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("Нет наблюдений за %s", start.Format("02.01.2006 MST"))
+	}
+	return result, nil
+}